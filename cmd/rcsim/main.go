@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"myruscoint/internal/emulator"
+	"myruscoint/internal/ruscoin"
+	"os"
+	"strings"
+)
+
+func main() {
+	if err := ruscoin.InitRuscoinSettings(); err != nil {
+		fmt.Println(err)
+	}
+
+	c := emulator.SimConfig{}
+	flag.IntVar(&c.Nodes, "nodes", 3, "number of nodes")
+	flag.IntVar(&c.Wallets, "wallets", 2, "number of user wallets")
+	flag.IntVar(&c.Ticks, "ticks", 10, "number of ticks to run")
+	flag.IntVar(&c.TxPerTick, "tx", 2, "random transactions before every tick")
+	flag.StringVar(&c.Attack, "attack", "", "tamper attack: "+strings.Join(emulator.AttackKinds, ", "))
+	flag.IntVar(&c.AttackEvery, "attack-every", 3, "make attack every N ticks")
+	flag.IntVar(&c.SelfishPower, "selfish", 0, "hash power of selfish miner node, honest nodes have power 1; 0 - no selfish miner")
+	flag.Float64Var(&c.Gamma, "gamma", 0, "share of honest nodes which see selfish miner block first in a tie race")
+	flag.IntVar(&c.DoubleSpendPower, "doublespend", 0, "hash power of double-spend attacker node; 0 - no attack")
+	flag.IntVar(&c.Confirmations, "confirmations", 2, "confirmations the double-spend victim waits for")
+	behaviours := flag.String("behaviours", "", "comma separated behaviours of Node1, Node2...: "+strings.Join(emulator.BehaviourKinds, ", ")+", kind:param to set parameter")
+	diff := flag.String("diff", ruscoin.MINE_DIFF, "mining difficulty")
+	reward := flag.Int("reward", ruscoin.REWARD_AMOUNT, "mining reward")
+	coinbase := flag.Int("coinbase", ruscoin.COINBASE_START_AMOUNT, "coinbase amount on start")
+	flag.IntVar(&c.MaxFee, "max-fee", 0, "max fee of random transactions")
+	flag.IntVar(&ruscoin.MAX_BLOCK_SIZE, "max-block-size", ruscoin.MAX_BLOCK_SIZE, "max block size in bytes")
+	flag.StringVar(&ruscoin.CHAIN_ID, "chain-id", ruscoin.CHAIN_ID, "chain id signed by transactions with replay protection")
+	flag.BoolVar(&ruscoin.REPLAY_PROTECTION, "replay-protection", ruscoin.REPLAY_PROTECTION, "sign chain id and utxo ids, reject reused utxo ids")
+	runs := flag.Int("runs", 1, "number of runs with the same settings")
+	out := flag.String("out", "", "write JSON summaries to file")
+	verbose := flag.Bool("v", false, "print emulation log to stderr")
+	scenario := flag.String("scenario", "", "run scenario file instead of random simulation")
+	flag.Int64Var(&c.Seed, "seed", ruscoin.SEED, "seed of deterministic mode, run N uses seed+N-1; 0 - random")
+	chain := flag.String("chain", "", "write the longest chain as JSON to file (file.N for run N if runs > 1)")
+	flag.Parse()
+
+	if _, ok := new(big.Int).SetString(*diff, 10); !ok {
+		fmt.Fprintln(os.Stderr, "diff must be integer")
+		os.Exit(2)
+	}
+	if ruscoin.MAX_BLOCK_SIZE < 1 {
+		fmt.Fprintln(os.Stderr, "max-block-size must be positive")
+		os.Exit(2)
+	}
+	ruscoin.MINE_DIFF = *diff
+	ruscoin.REWARD_AMOUNT = *reward
+	ruscoin.COINBASE_START_AMOUNT = *coinbase
+	if *behaviours != "" {
+		c.Behaviours = strings.Split(*behaviours, ",")
+	}
+
+	var l emulator.EmuLogger = emulator.NopLogger{}
+	if *verbose {
+		l = emulator.TextLogger{W: os.Stderr}
+	}
+
+	if *scenario != "" {
+		os.Exit(runScenario(*scenario, c.Seed, *chain, l))
+	}
+
+	summaries := make([]emulator.RunSummary, 0, *runs)
+	failed := false
+	seed := c.Seed
+	for r := 1; r <= *runs; r++ {
+		if seed != 0 {
+			c.Seed = seed + int64(r-1)
+		}
+		rm, err := emulator.RunHeadless(c, l)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Run %d: %s\n", r, err)
+			failed = true
+			if rm == nil {
+				break
+			}
+		}
+		s := rm.Summary()
+		summaries = append(summaries, s)
+		if *runs > 1 {
+			fmt.Printf("=== Run %d ===\n", r)
+		}
+		s.WriteText(os.Stdout)
+		fmt.Println()
+		if *chain != "" {
+			path := *chain
+			if *runs > 1 {
+				path = fmt.Sprintf("%s.%d", path, r)
+			}
+			if err = writeChain(rm, path); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+		}
+	}
+
+	if *out != "" {
+		data, err := json.MarshalIndent(summaries, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*out, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Runs scenario to the end and prints step results and summary. Returns exit code
+func runScenario(path string, seed int64, chain string, l emulator.EmuLogger) int {
+	sc, err := emulator.LoadScenario(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if sc.Settings.Seed == 0 {
+		sc.Settings.Seed = seed
+	}
+	r, err := emulator.NewScenarioRunner(sc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer r.Close()
+	failed := r.RunAll(l)
+	fmt.Printf("Scenario: %s\n", sc.Name)
+	for _, res := range r.Results {
+		status := "OK  "
+		if !res.OK {
+			status = "FAIL"
+		}
+		fmt.Printf("%s %3d %-14s %s\n", status, res.Index+1, res.Action, res.Msg)
+	}
+	fmt.Println()
+	r.Rm.Summary().WriteText(os.Stdout)
+	if chain != "" {
+		if err = writeChain(r.Rm, chain); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d steps failed\n", failed, len(r.Results))
+		return 1
+	}
+	return 0
+}
+
+func writeChain(rm *emulator.RuscoinMngr, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return rm.WriteChain(f)
+}
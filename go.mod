@@ -7,6 +7,8 @@ require (
 	github.com/ddulesov/gogost v1.0.0
 	github.com/labstack/echo/v4 v4.12.0
 	github.com/rs/xid v1.6.0
+	github.com/tyler-smith/go-bip39 v1.1.0
+	golang.org/x/crypto v0.26.0
 )
 
 require (
@@ -16,7 +18,6 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
-	golang.org/x/crypto v0.26.0 // indirect
 	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/sys v0.23.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
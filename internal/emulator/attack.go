@@ -0,0 +1,81 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+)
+
+// Block tampering attacks for headless runs
+const (
+	// Adds unsigned transaction which creates coins for the attacker
+	ATTACK_INFLATE = "inflate"
+	// Redirects outputs of the first user transaction to the attacker
+	ATTACK_REDIRECT = "redirect"
+	// Sets block height one above the expected
+	ATTACK_HEIGHT = "height"
+)
+
+var AttackKinds = []string{ATTACK_INFLATE, ATTACK_REDIRECT, ATTACK_HEIGHT}
+
+// Steals main node block candidate, tampers it according to kind, mines it with
+// the main node (without adding to it's chain) and sends it to all other online nodes.
+// Returns number of nodes which accepted the block
+func (rm *RuscoinMngr) EvilTamperAttack(kind string, attacker *ruscoin.Wallet, l EmuLogger) (int, error) {
+	n := rm.MainNode()
+	if n == nil {
+		return 0, fmt.Errorf("Evil: no main node set")
+	}
+	if n.BlockCandidate == nil {
+		return 0, fmt.Errorf("Evil: block candidate not set")
+	}
+	l.Evil("Stealing block candidate of node %s", n.Name)
+	b := n.BlockCandidate.Clone()
+
+	switch kind {
+	case ATTACK_INFLATE:
+		t := ruscoin.InitTransaction()
+		t.Sign = []byte{}
+		t.Pk = []byte{}
+		t.OutputUtxo.NewRecord(attacker.Addr, ruscoin.REWARD_AMOUNT*10)
+		b.AddTransaction(t)
+		l.Evil("Added transaction with %d coins to %s", ruscoin.REWARD_AMOUNT*10, attacker.Name)
+	case ATTACK_REDIRECT:
+		if len(b.Body.Transactions) == 0 {
+			return 0, fmt.Errorf("Evil: no transactions to redirect")
+		}
+		t := &b.Body.Transactions[0]
+		for id, u := range t.OutputUtxo {
+			t.UpdateOutputUtxo(id, u.Amount, attacker.Addr)
+		}
+		l.Evil("Redirected transaction outputs to %s", attacker.Name)
+	case ATTACK_HEIGHT:
+		b.Header.Height++
+		l.Evil("Height set to %d", b.Header.Height)
+	default:
+		return 0, fmt.Errorf("Evil: unknown attack %s", kind)
+	}
+
+	b, err := n.MineDetached(b)
+	if err != nil {
+		return 0, err
+	}
+	rm.EvilBlock = b.Clone()
+	l.Evil("Evil block mined with node %s, sending to other nodes", n.Name)
+
+	accepted := 0
+	for _, id := range rm.NodeIds() {
+		nd := rm.Nodes[id]
+		if nd.Id == n.Id || nd.Offline {
+			continue
+		}
+		if err := nd.AddVerifyBlock(b); err != nil {
+			rm.recordRejected(nd, b, err)
+			l.Error("Node [%s] rejected evil block: %s", nd.Name, err)
+			continue
+		}
+		accepted++
+		l.Evil("Node [%s] accepted evil block", nd.Name)
+		l.NodeUpdate(nd.Id)
+	}
+	return accepted, nil
+}
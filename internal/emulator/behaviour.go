@@ -0,0 +1,281 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node behaviour kinds
+const (
+	BEHAVIOUR_HONEST = "honest"
+	// Mines blocks with reward transaction only
+	BEHAVIOUR_LAZY = "lazy"
+	// Leaves out transactions of blacklisted addresses
+	BEHAVIOUR_CENSOR = "censor"
+	// Mines but never publishes its blocks
+	BEHAVIOUR_WITHHOLD = "withhold"
+	// Shifts block time by offset
+	BEHAVIOUR_TIMESTAMP = "timestamp"
+	// Sends tampered block to peers every tick
+	BEHAVIOUR_SPAM = "spam"
+)
+
+var BehaviourKinds = []string{BEHAVIOUR_HONEST, BEHAVIOUR_LAZY, BEHAVIOUR_CENSOR, BEHAVIOUR_WITHHOLD, BEHAVIOUR_TIMESTAMP, BEHAVIOUR_SPAM}
+
+// Default block time offset of timestamp manipulator
+const BEHAVIOUR_TIME_OFFSET = 2 * time.Hour
+
+// Strategy of the node on a tick. Protocol rules stay in ruscoin.Node,
+// behaviour decides what the node mines and publishes
+type Behaviour interface {
+	Kind() string
+	// Short description with parameters
+	String() string
+	// Changes block candidate of the node before it mines
+	PrepareBlock(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger)
+	// Whether block just mined by the node is sent to peers
+	Publish(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) bool
+	// Called every tick for online node after the block is delivered
+	Tick(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger)
+}
+
+// Creates behaviour of given kind. Parameter depends on kind: censor - comma separated
+// wallet names, addresses or utxo ids (all user transactions if empty), timestamp - offset in seconds,
+// spam - tamper attack kind
+func (rm *RuscoinMngr) NewBehaviour(kind, param string) (Behaviour, error) {
+	param = strings.TrimSpace(param)
+	switch kind {
+	case BEHAVIOUR_HONEST:
+		return HonestBehaviour{}, nil
+	case BEHAVIOUR_LAZY:
+		return LazyBehaviour{}, nil
+	case BEHAVIOUR_CENSOR:
+		b := &CensorBehaviour{}
+		for _, s := range strings.Split(param, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if w, err := rm.WalletByName(s); err == nil {
+				s = w.Addr
+			} else if _, ok := rm.Wallets[s]; !ok && !rm.knownUtxo(s) {
+				return nil, fmt.Errorf("Behaviour: wallet or utxo %s not found", s)
+			}
+			b.Blacklist = append(b.Blacklist, s)
+		}
+		return b, nil
+	case BEHAVIOUR_WITHHOLD:
+		return WithholdBehaviour{}, nil
+	case BEHAVIOUR_TIMESTAMP:
+		b := TimestampBehaviour{Offset: BEHAVIOUR_TIME_OFFSET}
+		if param != "" {
+			sec, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, fmt.Errorf("Behaviour: time offset is not integer")
+			}
+			b.Offset = time.Duration(sec) * time.Second
+		}
+		return b, nil
+	case BEHAVIOUR_SPAM:
+		b := SpamBehaviour{Attack: ATTACK_INFLATE}
+		if param != "" {
+			if !slices.Contains(AttackKinds, param) {
+				return nil, fmt.Errorf("Behaviour: unknown attack %s", param)
+			}
+			b.Attack = param
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("Behaviour: unknown kind %s", kind)
+}
+
+// Behaviour of the node, honest if not set
+func (rm *RuscoinMngr) Behaviour(n *ruscoin.Node) Behaviour {
+	if b, ok := rm.Behaviours[n.Id]; ok {
+		return b
+	}
+	return HonestBehaviour{}
+}
+
+func (rm *RuscoinMngr) SetBehaviour(id string, b Behaviour) error {
+	if _, err := rm.GetNode(id); err != nil {
+		return err
+	}
+	n := rm.Nodes[id]
+	n.Policy = nil
+	if cb, ok := b.(*CensorBehaviour); ok {
+		n.Policy = &cb.MinerPolicy
+	}
+	if b.Kind() == BEHAVIOUR_HONEST {
+		delete(rm.Behaviours, id)
+		return nil
+	}
+	rm.Behaviours[id] = b
+	return nil
+}
+
+// Utxo id is unspent or spent on some node
+func (rm *RuscoinMngr) knownUtxo(id string) bool {
+	for _, n := range rm.Nodes {
+		if _, ok := n.Utxo[id]; ok {
+			return true
+		}
+		if _, ok := n.Spent[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs behaviours of all online nodes
+func (rm *RuscoinMngr) behaviourTick(l EmuLogger) {
+	for _, id := range rm.NodeIds() {
+		if b, ok := rm.Behaviours[id]; ok && !rm.Nodes[id].Offline {
+			b.Tick(rm, rm.Nodes[id], l)
+		}
+	}
+}
+
+type HonestBehaviour struct{}
+
+func (HonestBehaviour) Kind() string   { return BEHAVIOUR_HONEST }
+func (HonestBehaviour) String() string { return BEHAVIOUR_HONEST }
+
+func (HonestBehaviour) PrepareBlock(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) {}
+
+func (HonestBehaviour) Publish(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) bool { return true }
+
+func (HonestBehaviour) Tick(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) {}
+
+type LazyBehaviour struct{ HonestBehaviour }
+
+func (LazyBehaviour) Kind() string   { return BEHAVIOUR_LAZY }
+func (LazyBehaviour) String() string { return BEHAVIOUR_LAZY }
+
+func (LazyBehaviour) PrepareBlock(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) {
+	if c := n.BlockCandidate; c != nil && len(c.Body.Transactions) > 0 {
+		l.Evil("Node [%s]: lazy miner leaves out %d transactions", n.Name, len(c.Body.Transactions))
+		c.Body.Transactions = nil
+		c.Header.Root = nil
+	}
+}
+
+// Miner with blacklist policy. The node does not put censored transactions
+// into its block candidate, they wait in mempools for another miner
+type CensorBehaviour struct {
+	HonestBehaviour
+	ruscoin.MinerPolicy
+}
+
+func (*CensorBehaviour) Kind() string { return BEHAVIOUR_CENSOR }
+
+func (b *CensorBehaviour) String() string {
+	if len(b.Blacklist) == 0 {
+		return BEHAVIOUR_CENSOR + " all"
+	}
+	return fmt.Sprintf("%s %d entries", BEHAVIOUR_CENSOR, len(b.Blacklist))
+}
+
+func (b *CensorBehaviour) PrepareBlock(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) {
+	c := n.BlockCandidate
+	if c == nil {
+		return
+	}
+	before := len(c.Body.Transactions)
+	c.Body.Transactions = slices.DeleteFunc(c.Body.Transactions, b.Censored)
+	if cnt := before - len(c.Body.Transactions); cnt > 0 {
+		c.Header.Root = nil
+		l.Evil("Node [%s]: censoring miner leaves out %d transactions", n.Name, cnt)
+	}
+}
+
+// Transaction spends or pays to blacklisted address or spends blacklisted utxo
+func (b *CensorBehaviour) Censored(t ruscoin.Transaction) bool {
+	return b.Censors(&t)
+}
+
+type WithholdBehaviour struct{ HonestBehaviour }
+
+func (WithholdBehaviour) Kind() string   { return BEHAVIOUR_WITHHOLD }
+func (WithholdBehaviour) String() string { return BEHAVIOUR_WITHHOLD }
+
+func (WithholdBehaviour) Publish(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) bool {
+	l.Evil("Node [%s]: withholding miner keeps block %d", n.Name, len(n.BlockChain)-1)
+	return false
+}
+
+type TimestampBehaviour struct {
+	HonestBehaviour
+	Offset time.Duration
+}
+
+func (TimestampBehaviour) Kind() string { return BEHAVIOUR_TIMESTAMP }
+
+func (b TimestampBehaviour) String() string {
+	return fmt.Sprintf("%s %+ds", BEHAVIOUR_TIMESTAMP, int(b.Offset.Seconds()))
+}
+
+func (b TimestampBehaviour) PrepareBlock(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) {
+	if c := n.BlockCandidate; c != nil {
+		c.Header.Time = c.Header.Time.Add(b.Offset)
+		l.Evil("Node [%s]: block time shifted by %s", n.Name, b.Offset)
+	}
+}
+
+type SpamBehaviour struct {
+	HonestBehaviour
+	Attack string
+}
+
+func (SpamBehaviour) Kind() string { return BEHAVIOUR_SPAM }
+
+func (b SpamBehaviour) String() string {
+	return BEHAVIOUR_SPAM + " " + b.Attack
+}
+
+// Builds block on top of node chain, tampers it and sends it to peers
+func (b SpamBehaviour) Tick(rm *RuscoinMngr, n *ruscoin.Node, l EmuLogger) {
+	cur := n.BlockCandidate
+	blk := n.NewBlockCandidate().Clone()
+	n.BlockCandidate = cur
+	switch b.Attack {
+	case ATTACK_INFLATE:
+		t := ruscoin.InitTransaction()
+		t.Sign = []byte{}
+		t.Pk = []byte{}
+		t.OutputUtxo.NewRecord(n.Wallet.Addr, ruscoin.REWARD_AMOUNT*10)
+		blk.AddTransaction(t)
+	case ATTACK_REDIRECT:
+		if len(blk.Body.Transactions) == 0 {
+			return
+		}
+		t := &blk.Body.Transactions[0]
+		for id, u := range t.OutputUtxo {
+			t.UpdateOutputUtxo(id, u.Amount, n.Wallet.Addr)
+		}
+	case ATTACK_HEIGHT:
+		blk.Header.Height++
+	}
+	blk, err := n.MineDetached(blk)
+	if err != nil {
+		l.Error("Node [%s]: spam block: %s", n.Name, err)
+		return
+	}
+	rejected := 0
+	for _, p := range rm.peers(n) {
+		if p.Offline {
+			continue
+		}
+		if err := p.AddVerifyBlock(blk); err != nil {
+			rm.recordRejected(p, blk, err)
+			rejected++
+			continue
+		}
+		l.Evil("Node [%s] accepted spam block of [%s]", p.Name, n.Name)
+		l.NodeUpdate(p.Id)
+	}
+	l.Evil("Node [%s]: spam block (%s) rejected by %d peers", n.Name, b.Attack, rejected)
+}
@@ -0,0 +1,148 @@
+package emulator
+
+import (
+	"slices"
+
+	"myruscoint/internal/ruscoin"
+)
+
+// Max number of included transactions kept in wait statistics
+const TX_WAITS_KEEP = 200
+
+// How long submitted transaction waited in mempools until it got into the public chain
+type TxWait struct {
+	Tx     ruscoin.Transaction
+	From   string
+	To     string
+	Amount int
+	// Tick of submit and public chain length at that moment
+	Submitted int
+	Height    int
+	// Tick when transaction appeared in the public chain, -1 while pending
+	Mined int
+	// Blocks mined by censoring miners while transaction waited
+	Skipped int
+	// Some miner policy censors the transaction
+	Censored bool
+}
+
+// Ticks transaction waited so far or until it was mined
+func (w *TxWait) Waited(tick int) int {
+	if w.Mined >= 0 {
+		return w.Mined - w.Submitted
+	}
+	return tick - w.Submitted
+}
+
+// Starts tracking wait time of submitted wallet transaction
+func (rm *RuscoinMngr) trackTransaction(w *ruscoin.Wallet, t *ruscoin.Transaction) {
+	tw := &TxWait{
+		Tx:        *t,
+		From:      w.Name,
+		Submitted: rm.Tick,
+		Height:    rm.publicLen(),
+		Mined:     -1,
+	}
+	for _, u := range t.OutputUtxo.SortedItems() {
+		if !w.Owns(u.Addr) {
+			tw.To = rm.walletLabel(u.Addr)
+			tw.Amount += u.Amount
+		}
+	}
+	for _, n := range rm.Nodes {
+		if n.Policy.Censors(t) {
+			tw.Censored = true
+		}
+	}
+	// pending transactions replaced by fee bump will never be mined
+	rm.TxWaits = slices.DeleteFunc(rm.TxWaits, func(p *TxWait) bool {
+		return p.Mined < 0 && p.Tx.Conflicts(t)
+	})
+	rm.TxWaits = append(rm.TxWaits, tw)
+}
+
+// Wallet or multisig account name of the address or the address itself
+func (rm *RuscoinMngr) walletLabel(addr string) string {
+	if w := rm.WalletOf(addr); w != nil {
+		return w.Name
+	}
+	if a, ok := rm.MultisigByAddr(addr); ok {
+		return a.Name
+	}
+	return addr
+}
+
+// Counts blocks of censoring miner n which left out pending transactions and marks
+// transactions included into the public chain. Called after tick counter is increased
+func (rm *RuscoinMngr) censorTick(n *ruscoin.Node, mined bool, l EmuLogger) {
+	pub := rm.PublicNode()
+	for _, w := range rm.TxWaits {
+		if w.Mined >= 0 {
+			continue
+		}
+		if mined && n.Policy.Censors(&w.Tx) {
+			w.Skipped++
+			w.Censored = true
+			l.Evil("Node [%s]: censored transaction %s -> %s waits %d ticks", n.Name, w.From, w.To, w.Waited(rm.Tick))
+		}
+		if pub != nil && chainHasTransaction(pub.BlockChain[min(w.Height, len(pub.BlockChain)):], &w.Tx) {
+			w.Mined = rm.Tick
+			if w.Censored {
+				l.OK("Censored transaction %s -> %s included after %d ticks", w.From, w.To, w.Waited(rm.Tick))
+			}
+		}
+	}
+	rm.pruneTxWaits()
+}
+
+// Forgets the oldest included transactions above TX_WAITS_KEEP
+func (rm *RuscoinMngr) pruneTxWaits() {
+	mined := 0
+	for _, w := range rm.TxWaits {
+		if w.Mined >= 0 {
+			mined++
+		}
+	}
+	res := rm.TxWaits[:0]
+	for _, w := range rm.TxWaits {
+		if w.Mined >= 0 && mined > TX_WAITS_KEEP {
+			mined--
+			continue
+		}
+		res = append(res, w)
+	}
+	rm.TxWaits = res
+}
+
+// Average wait in ticks of included transactions, censored and not, and their counts
+func (rm *RuscoinMngr) AverageWaits() (censored float64, nc int, other float64, no int) {
+	for _, w := range rm.TxWaits {
+		if w.Mined < 0 {
+			continue
+		}
+		if w.Censored {
+			censored += float64(w.Waited(rm.Tick))
+			nc++
+		} else {
+			other += float64(w.Waited(rm.Tick))
+			no++
+		}
+	}
+	if nc > 0 {
+		censored /= float64(nc)
+	}
+	if no > 0 {
+		other /= float64(no)
+	}
+	return censored, nc, other, no
+}
+
+// Last tracked transaction sent by the wallet, nil if there is none
+func (rm *RuscoinMngr) LastTxWait(wallet string) *TxWait {
+	for i := len(rm.TxWaits) - 1; i >= 0; i-- {
+		if rm.TxWaits[i].From == wallet {
+			return rm.TxWaits[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"myruscoint/internal/ruscoin"
+)
+
+// Attack is considered safe for the victim if attacker success probability is below the risk
+const DOUBLE_SPEND_RISK = 0.001
+
+// Attacker gives up when public chain is ahead of the private fork by more blocks
+const DOUBLE_SPEND_GIVE_UP = 6
+
+// Double-spend attack. Attacker node wallet pays victim, honest nodes confirm the payment,
+// attacker meanwhile mines a private fork where the same utxo go back to the attacker,
+// and releases the fork after the victim sees enough confirmations
+type DoubleSpendAttack struct {
+	Node   *ruscoin.Node
+	Victim *ruscoin.Wallet
+	Amount int
+	// Confirmations victim waits before accepting the payment
+	Confirmations int
+	// Payment to the victim and conflicting transaction of the private fork
+	Payment  *ruscoin.Transaction
+	Conflict *ruscoin.Transaction
+	// Chain length at attack start, private fork grows from here
+	ForkLen int
+	// Height of public block with the payment, -1 while not mined
+	PayHeight int
+	StartTick int
+	// Attacker hash power share at attack start, 0..1
+	HashShare float64
+	Done      bool
+	Reversed  bool
+	// Payment confirmations when attack finished, before the fork release
+	FinalConfirmations int
+	Result             string
+}
+
+// Starts double-spend attack by node with id. Attacker node wallet pays amount to victim
+// and victim waits for confirmations blocks
+func (rm *RuscoinMngr) StartDoubleSpend(id, victimAddr string, amount, confirmations int, l EmuLogger) (*DoubleSpendAttack, error) {
+	if rm.Selfish != nil || rm.DoubleSpend.Running() {
+		return nil, fmt.Errorf("DoubleSpend: another attack is running")
+	}
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	victim, ok := rm.Wallets[victimAddr]
+	if !ok {
+		return nil, fmt.Errorf("DoubleSpend: victim wallet %s not found", victimAddr)
+	}
+	w := n.Wallet
+	if w == nil || w == victim {
+		return nil, fmt.Errorf("DoubleSpend: attacker and victim wallets must differ")
+	}
+	if confirmations < 1 {
+		return nil, fmt.Errorf("DoubleSpend: confirmations must be positive")
+	}
+	if amount < 1 {
+		return nil, fmt.Errorf("DoubleSpend: amount must be positive")
+	}
+	if len(rm.Nodes) < 2 {
+		return nil, fmt.Errorf("DoubleSpend: at least one honest node required")
+	}
+	if rm.Tick == 0 {
+		return nil, fmt.Errorf("DoubleSpend: attack can be started after genesis block")
+	}
+	// attacker forks from the current public chain
+	if pub := rm.PublicNode(); pub != nil && len(pub.BlockChain) > len(n.BlockChain) {
+		if _, err := n.ReceiveChain(pub.BlockChain); err != nil {
+			return nil, err
+		}
+	}
+	rm.SyncWallets(l)
+
+	pay, err := w.SendManyFrom(rm.SpendableUtxo(w, rm.EntryNode(w)), []ruscoin.Payment{{Addr: victim.Addr, Amount: amount}})
+	if err != nil {
+		return nil, fmt.Errorf("DoubleSpend: %s", err)
+	}
+	inputs := pay.InputUtxo
+	// the same inputs, all coins back to the attacker
+	conflict := ruscoin.NewTransaction().SetInputUtxo(inputs)
+	conflict.OutputUtxo.NewRecord(w.Addr, inputs.Sum())
+	if err := w.SignTransaction(conflict); err != nil {
+		return nil, err
+	}
+
+	ds := &DoubleSpendAttack{
+		Node:          n,
+		Victim:        victim,
+		Amount:        amount,
+		Confirmations: confirmations,
+		Payment:       pay,
+		Conflict:      conflict,
+		ForkLen:       len(n.BlockChain),
+		PayHeight:     -1,
+		StartTick:     rm.Tick,
+		HashShare:     rm.hashShare(n),
+	}
+	rm.DoubleSpend = ds
+	if m := rm.MainNode(); m != nil {
+		rm.doubleSpendPrepare(m)
+	}
+	l.Evil("DoubleSpend: attacker [%s] pays %d coins to %s and starts private fork at height %d", n.Name, amount, victim.Name, ds.ForkLen)
+	return ds, nil
+}
+
+// Aborts running attack without releasing the private fork
+func (rm *RuscoinMngr) StopDoubleSpend(l EmuLogger) error {
+	ds := rm.DoubleSpend
+	if !ds.Running() {
+		return fmt.Errorf("DoubleSpend: attack is not running")
+	}
+	if pub := rm.PublicNode(); pub != nil {
+		ds.FinalConfirmations = ds.confirmationsAt(len(pub.BlockChain))
+	}
+	rm.doubleSpendFinish("aborted", l)
+	return nil
+}
+
+func (ds *DoubleSpendAttack) Running() bool {
+	return ds != nil && !ds.Done
+}
+
+// Length of the private fork
+func (ds *DoubleSpendAttack) PrivateLen() int {
+	return len(ds.Node.BlockChain) - ds.ForkLen
+}
+
+// Payment confirmations in the chain of given length
+func (ds *DoubleSpendAttack) confirmationsAt(chainLen int) int {
+	if ds.PayHeight < 0 {
+		return 0
+	}
+	return max(chainLen-ds.PayHeight, 0)
+}
+
+// Attacker success probability for every number of confirmations from 0 to n
+func (ds *DoubleSpendAttack) Probabilities(n int) []float64 {
+	res := make([]float64, n+1)
+	for z := range res {
+		res[z] = CatchUpProbability(ds.HashShare, z)
+	}
+	return res
+}
+
+// Puts transaction of the attack side into block candidate of the miner
+func (rm *RuscoinMngr) doubleSpendPrepare(miner *ruscoin.Node) {
+	ds := rm.DoubleSpend
+	t := ds.Payment
+	if miner == ds.Node {
+		t = ds.Conflict
+		// wallets follow the public chain, attacker drops transactions invalid on its fork
+		if c := miner.BlockCandidate; c != nil {
+			txs := c.Body.Transactions
+			miner.NewBlockCandidate()
+			for _, ct := range txs {
+				if miner.Utxo.Contains(ct.InputUtxo) {
+					miner.AddVerifyTransaction(ct)
+				}
+			}
+		}
+	} else if ds.PayHeight >= 0 {
+		return
+	}
+	if chainHasTransaction(miner.BlockChain[min(ds.ForkLen, len(miner.BlockChain)):], t) {
+		return
+	}
+	if miner.BlockCandidate != nil && blockHasTransaction(miner.BlockCandidate, t) {
+		return
+	}
+	miner.AddVerifyTransaction(t.Clone())
+}
+
+// Handles block just mined by miner
+func (rm *RuscoinMngr) doubleSpendTick(miner *ruscoin.Node, l EmuLogger) {
+	ds := rm.DoubleSpend
+	att := ds.Node
+	if miner == att {
+		l.Evil("DoubleSpend: attacker [%s] mined private block %d", att.Name, len(att.BlockChain)-1)
+	} else {
+		for _, id := range rm.NodeIds() {
+			if n := rm.Nodes[id]; n != miner && n != att {
+				rm.deliverChain(miner.BlockChain, n, l)
+			}
+		}
+	}
+
+	pub := rm.PublicNode()
+	if pub == nil {
+		return
+	}
+	if ds.PayHeight < 0 {
+		for i, b := range pub.BlockChain[min(ds.ForkLen, len(pub.BlockChain)):] {
+			if blockHasTransaction(b, ds.Payment) {
+				ds.PayHeight = ds.ForkLen + i
+				l.Evil("DoubleSpend: payment to %s mined in public block %d", ds.Victim.Name, ds.PayHeight)
+			}
+		}
+	}
+	pubLen := len(pub.BlockChain)
+	conf := ds.confirmationsAt(pubLen)
+	switch {
+	case conf >= ds.Confirmations && len(att.BlockChain) > pubLen:
+		l.Evil("DoubleSpend: victim has %d confirmations, attacker releases private fork of %d blocks", conf, ds.PrivateLen())
+		ds.FinalConfirmations = conf
+		for _, id := range rm.NodeIds() {
+			if n := rm.Nodes[id]; n != att {
+				rm.deliverChain(att.BlockChain, n, l)
+			}
+		}
+		rm.doubleSpendFinish("released", l)
+	case pubLen-len(att.BlockChain) > DOUBLE_SPEND_GIVE_UP:
+		l.Evil("DoubleSpend: attacker is %d blocks behind and gives up", pubLen-len(att.BlockChain))
+		ds.FinalConfirmations = conf
+		rm.doubleSpendFinish("gave up", l)
+	default:
+		rm.SyncWallets(l)
+	}
+}
+
+// Finishes attack: checks whether payment was reversed and makes attacker honest again
+func (rm *RuscoinMngr) doubleSpendFinish(result string, l EmuLogger) {
+	ds := rm.DoubleSpend
+	ds.Done = true
+	ds.Result = result
+	if pub := rm.PublicNode(); pub != nil {
+		tail := pub.BlockChain[min(ds.ForkLen, len(pub.BlockChain)):]
+		ds.Reversed = ds.PayHeight >= 0 && !chainHasTransaction(tail, ds.Payment)
+		if len(pub.BlockChain) > len(ds.Node.BlockChain) {
+			rm.deliverChain(pub.BlockChain, ds.Node, l)
+		}
+	}
+	rm.SyncWallets(l)
+	if ds.Reversed {
+		l.Evil("DoubleSpend: payment to %s reversed after %d confirmations", ds.Victim.Name, ds.FinalConfirmations)
+	} else {
+		l.Info("DoubleSpend: attack %s, payment to %s is not reversed", result, ds.Victim.Name)
+	}
+	if safe := SafeConfirmations(ds.HashShare, DOUBLE_SPEND_RISK); safe < 0 {
+		l.Info("DoubleSpend: attacker hash share %.1f%% is majority, no number of confirmations is safe", 100*ds.HashShare)
+	} else {
+		l.Info("DoubleSpend: with attacker hash share %.1f%% %d confirmations are safe", 100*ds.HashShare, safe)
+	}
+}
+
+// Hash power share of the node among online nodes, 0..1
+func (rm *RuscoinMngr) hashShare(n *ruscoin.Node) float64 {
+	total := 0
+	for _, nd := range rm.Nodes {
+		if !nd.Offline {
+			total += nd.HashPower
+		}
+	}
+	if total == 0 || n.Offline {
+		return 0
+	}
+	return float64(n.HashPower) / float64(total)
+}
+
+// Probability that attacker with hash share q ever catches up being z blocks behind
+// (Nakamoto, Bitcoin whitepaper, section 11)
+func CatchUpProbability(q float64, z int) float64 {
+	p := 1 - q
+	if q >= p {
+		return 1
+	}
+	lambda := float64(z) * q / p
+	sum := 1.0
+	for k := 0; k <= z; k++ {
+		poisson := math.Exp(-lambda)
+		for i := 1; i <= k; i++ {
+			poisson *= lambda / float64(i)
+		}
+		sum -= poisson * (1 - math.Pow(q/p, float64(z-k)))
+	}
+	return max(sum, 0)
+}
+
+// Minimal number of confirmations with attacker success probability below risk,
+// -1 if attacker has majority and no number is safe
+func SafeConfirmations(q, risk float64) int {
+	if q >= 0.5 {
+		return -1
+	}
+	z := 0
+	for CatchUpProbability(q, z) >= risk {
+		z++
+	}
+	return z
+}
+
+func chainHasTransaction(chain []*ruscoin.Block, t *ruscoin.Transaction) bool {
+	for _, b := range chain {
+		if blockHasTransaction(b, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockHasTransaction(b *ruscoin.Block, t *ruscoin.Transaction) bool {
+	for _, bt := range b.Body.Transactions {
+		if bytes.Equal(bt.Sign, t.Sign) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,439 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Evil block header and body fields which can be set by EvilSetField
+var EvilFields = []string{"height", "time", "root", "prev", "hash", "nonce", "coinbase"}
+
+// Copies main node block candidate to evil block
+func (rm *RuscoinMngr) EvilSteal() (*ruscoin.Block, error) {
+	n := rm.MainNode()
+	if n == nil {
+		return nil, fmt.Errorf("Evil: main node not set")
+	}
+	if n.BlockCandidate == nil {
+		return nil, fmt.Errorf("Evil: block candidate not set")
+	}
+	rm.EvilBlock = n.BlockCandidate.Clone()
+	return rm.EvilBlock, nil
+}
+
+// Sets evil block field from string value. Time format is "2006-01-02 15:04:05", hashes are hex strings
+func (rm *RuscoinMngr) EvilSetField(field, value string) error {
+	b := rm.EvilBlock
+	if b == nil {
+		return fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	switch field {
+	case "height", "nonce", "coinbase":
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Evil: %s value is not integer", field)
+		}
+		switch field {
+		case "height":
+			b.Header.Height = v
+		case "nonce":
+			b.Header.Nonce = v
+		case "coinbase":
+			b.Body.Coinbase = v
+		}
+	case "time":
+		t, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			return fmt.Errorf("Evil: wrong date time format. Must be YYYY-mm-dd hh:mm:ss")
+		}
+		b.Header.Time = t
+	case "root", "prev", "hash":
+		h, err := ruscoin.StringToBytes(value)
+		if err != nil {
+			return fmt.Errorf("Evil: hash value is not hex string")
+		}
+		switch field {
+		case "root":
+			b.Header.Root = h
+		case "prev":
+			b.Header.Prev = h
+		case "hash":
+			b.Header.Hash = h
+		}
+	default:
+		return fmt.Errorf("Evil: unknown field %s", field)
+	}
+	return nil
+}
+
+// Adds unsigned transaction which sends amount of new coins to addr. Returns transaction index
+func (rm *RuscoinMngr) EvilAddCoins(addr string, amount int) (int, error) {
+	if rm.EvilBlock == nil {
+		return 0, fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	t := ruscoin.InitTransaction()
+	t.Sign = []byte{}
+	t.Pk = []byte{}
+	t.OutputUtxo.NewRecord(addr, amount)
+	return rm.EvilBlock.AddTransaction(t), nil
+}
+
+// Mines evil block with the main node and adds it to main node chain without verification
+func (rm *RuscoinMngr) EvilMine() (*ruscoin.Block, error) {
+	if rm.EvilBlock == nil {
+		return nil, fmt.Errorf("Evil: evil block not set. Steal block first.")
+	}
+	n := rm.MainNode()
+	if n == nil {
+		return nil, fmt.Errorf("Evil: no main node set")
+	}
+	n.BlockCandidate = rm.EvilBlock
+	b, err := n.MineUnsafe()
+	if err != nil {
+		return nil, fmt.Errorf("Evil: Failed to mine block")
+	}
+	rm.EvilBlock = b.Clone()
+	// main node goes on mining on top of the evil block
+	n.NewBlockCandidate()
+	return b, nil
+}
+
+// Sets evil block as main node block candidate
+func (rm *RuscoinMngr) EvilInject() error {
+	if rm.EvilBlock == nil {
+		return fmt.Errorf("Evil: evil block not set. Steal block first.")
+	}
+	n := rm.MainNode()
+	if n == nil {
+		return fmt.Errorf("Evil: no main node set")
+	}
+	n.BlockCandidate = rm.EvilBlock.Clone()
+	return nil
+}
+
+// Sends evil block from the main node to other online nodes. Returns number of nodes accepted the block
+func (rm *RuscoinMngr) EvilSend(l EmuLogger) (int, error) {
+	if rm.EvilBlock == nil {
+		return 0, fmt.Errorf("Evil: evil block not set. Steal block first.")
+	}
+	n := rm.MainNode()
+	if n == nil {
+		return 0, fmt.Errorf("Evil: no main node set")
+	}
+	accepted := 0
+	for _, id := range rm.NodeIds() {
+		nd := rm.Nodes[id]
+		if id == n.Id || nd.Offline {
+			continue
+		}
+		if err := nd.AddVerifyBlock(rm.EvilBlock); err != nil {
+			rm.recordRejected(nd, rm.EvilBlock, err)
+			l.Error("Node [%s] add block failed: %s", nd.Name, err)
+			continue
+		}
+		accepted++
+		l.Evil("Node [%s] accepted evil block", nd.Name)
+	}
+	if accepted > 0 {
+		rm.SyncWallets(l)
+	}
+	return accepted, nil
+}
+
+// Evil block fix-ups which recompute fields made stale by editing, see EvilFix
+const (
+	EVIL_FIX_ROOT    = "root"
+	EVIL_FIX_HASH    = "hash"
+	EVIL_FIX_SIGN    = "sign"
+	EVIL_FIX_BALANCE = "balance"
+)
+
+var EvilFixes = []string{EVIL_FIX_ROOT, EVIL_FIX_HASH, EVIL_FIX_SIGN, EVIL_FIX_BALANCE}
+
+// Applies fix-up to evil block. Transaction index tid is used by sign and balance,
+// sign also needs address of the wallet whose key signs the transaction
+func (rm *RuscoinMngr) EvilFix(fix string, tid int, addr string, l EmuLogger) error {
+	switch fix {
+	case EVIL_FIX_ROOT:
+		return rm.EvilFixRoot(l)
+	case EVIL_FIX_HASH:
+		return rm.EvilRemine(l)
+	case EVIL_FIX_SIGN:
+		return rm.EvilResign(tid, addr, l)
+	case EVIL_FIX_BALANCE:
+		_, err := rm.EvilRebalance(tid, l)
+		return err
+	}
+	return fmt.Errorf("Evil: unknown fix %s", fix)
+}
+
+// Recomputes merkle root of evil block transactions
+func (rm *RuscoinMngr) EvilFixRoot(l EmuLogger) error {
+	b := rm.EvilBlock
+	if b == nil {
+		return fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	r, err := b.CalcMerkleRoot()
+	if err != nil {
+		return fmt.Errorf("Evil: failed to calculate merkle root: %s", err)
+	}
+	b.Header.Root = r
+	l.Evil("Evil: merkle root recomputed %s", b.RootString())
+	return nil
+}
+
+// Finds nonce and hash for current evil block header. Unlike EvilMine the block
+// keeps its transactions and is not added to the chain
+func (rm *RuscoinMngr) EvilRemine(l EmuLogger) error {
+	b := rm.EvilBlock
+	if b == nil {
+		return fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	nonce, h, err := ruscoin.MineBlock(b)
+	if err != nil {
+		return fmt.Errorf("Evil: failed to mine block: %s", err)
+	}
+	b.Header.Nonce = nonce
+	b.Header.Hash = h
+	l.Evil("Evil: block remined, nonce %d hash %s", nonce, b.HashString())
+	return nil
+}
+
+// Signs evil block transaction with key of wallet addr
+func (rm *RuscoinMngr) EvilResign(tid int, addr string, l EmuLogger) error {
+	t, err := rm.evilTransaction(tid)
+	if err != nil {
+		return err
+	}
+	w, ok := rm.Wallets[addr]
+	if !ok {
+		return fmt.Errorf("Evil: wallet %s not found", addr)
+	}
+	if err := w.SignTransaction(t); err != nil {
+		return err
+	}
+	l.Evil("Evil: transaction %d signed by %s", tid, w.Name)
+	if len(w.OwnUtxo(t.InputUtxo)) != len(t.InputUtxo) {
+		l.Info("Evil: %s does not own all inputs of transaction %d", w.Name, tid)
+	}
+	return nil
+}
+
+// Makes outputs of evil block transaction sum up to its inputs. Surplus goes to the
+// last output or back to the first input owner, shortage is taken from the last outputs.
+// Returns change of outputs sum
+func (rm *RuscoinMngr) EvilRebalance(tid int, l EmuLogger) (int, error) {
+	t, err := rm.evilTransaction(tid)
+	if err != nil {
+		return 0, err
+	}
+	diff := t.InputUtxo.Sum() - t.OutputUtxo.Sum()
+	if diff == 0 {
+		l.Info("Evil: transaction %d is already balanced", tid)
+		return 0, nil
+	}
+	ids := []string{}
+	for id := range t.OutputUtxo.SortedItems() {
+		ids = append(ids, id)
+	}
+	if diff > 0 {
+		if len(ids) == 0 {
+			addr := ""
+			for _, u := range t.InputUtxo.SortedItems() {
+				addr = u.Addr
+				break
+			}
+			t.OutputUtxo.NewRecord(addr, diff)
+		} else {
+			u := t.OutputUtxo[ids[len(ids)-1]]
+			t.UpdateOutputUtxo(ids[len(ids)-1], u.Amount+diff, u.Addr)
+		}
+	} else {
+		left := -diff
+		for i := len(ids) - 1; i >= 0 && left > 0; i-- {
+			u := t.OutputUtxo[ids[i]]
+			if u.Amount <= left {
+				left -= u.Amount
+				t.DeleteOutputUtxo(ids[i])
+				continue
+			}
+			t.UpdateOutputUtxo(ids[i], u.Amount-left, u.Addr)
+			left = 0
+		}
+	}
+	l.Evil("Evil: transaction %d outputs rebalanced by %+d coins", tid, diff)
+	return diff, nil
+}
+
+func (rm *RuscoinMngr) evilTransaction(tid int) (*ruscoin.Transaction, error) {
+	b := rm.EvilBlock
+	if b == nil {
+		return nil, fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	if tid < 0 || tid >= len(b.Body.Transactions) {
+		return nil, fmt.Errorf("Evil: transaction %d not found", tid)
+	}
+	return &b.Body.Transactions[tid], nil
+}
+
+// Serialised evil block as hex text, one field per line with offset and field name in comment
+func (rm *RuscoinMngr) EvilRawText() (string, int, error) {
+	if rm.EvilBlock == nil {
+		return "", 0, fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	data, fields := rm.EvilBlock.SerializeAnnotated()
+	sb := strings.Builder{}
+	for _, f := range fields {
+		fmt.Fprintf(&sb, "%s  # %04x %s\n", ruscoin.BytesToString(data[f.Offset:f.Offset+f.Size]), f.Offset, f.Name)
+	}
+	return sb.String(), len(data), nil
+}
+
+// Parses hex text of serialised block and replaces evil block with it.
+// Text after # up to the end of line and whitespace are ignored
+func (rm *RuscoinMngr) EvilRawApply(text string, l EmuLogger) error {
+	if rm.EvilBlock == nil {
+		return fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	sb := strings.Builder{}
+	for _, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		sb.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	data, err := ruscoin.StringToBytes(sb.String())
+	if err != nil {
+		return fmt.Errorf("Evil: raw block is not hex string: %s", err)
+	}
+	b, err := ruscoin.ParseBlock(data)
+	if err != nil {
+		return fmt.Errorf("Evil: %s", err)
+	}
+	rm.EvilBlock = b
+	l.Evil("Evil: evil block replaced by raw bytes, %d bytes", len(data))
+	return nil
+}
+
+// Replaces bytes of serialised evil block field (name as in EvilRawText) with hex value
+// and parses the result. Value may have different length than the field
+func (rm *RuscoinMngr) EvilRawPatch(field, value string, l EmuLogger) error {
+	if rm.EvilBlock == nil {
+		return fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	v, err := ruscoin.StringToBytes(value)
+	if err != nil {
+		return fmt.Errorf("Evil: value is not hex string")
+	}
+	data, fields := rm.EvilBlock.SerializeAnnotated()
+	for _, f := range fields {
+		if f.Name != field {
+			continue
+		}
+		patched := slices.Concat(data[:f.Offset], v, data[f.Offset+f.Size:])
+		b, err := ruscoin.ParseBlock(patched)
+		if err != nil {
+			return fmt.Errorf("Evil: %s", err)
+		}
+		rm.EvilBlock = b
+		l.Evil("Evil: raw field %s set to %s", field, value)
+		return nil
+	}
+	return fmt.Errorf("Evil: raw field %s not found", field)
+}
+
+// Copies transaction of block height of node chain into evil block, which is stolen
+// first if not set. Returns evil transaction index
+func (rm *RuscoinMngr) EvilReplay(nodeId string, height, index int, l EmuLogger) (int, error) {
+	n, err := rm.GetNode(nodeId)
+	if err != nil {
+		return 0, err
+	}
+	if height < 0 || height >= len(n.BlockChain) {
+		return 0, fmt.Errorf("Evil: node %s has no block %d", n.Name, height)
+	}
+	b := n.BlockChain[height]
+	if index < 1 || index >= len(b.Body.Transactions) {
+		return 0, fmt.Errorf("Evil: block %d has no transaction %d, reward transaction can not be replayed", height, index)
+	}
+	t := b.Body.Transactions[index].Clone()
+	l.Evil("Evil: replaying transaction %d of block %d from node [%s]", index, height, n.Name)
+	l.Info("Evil: raw transaction %s", ruscoin.BytesToString(t.Serialize()))
+	return rm.evilReplay(t, l)
+}
+
+// Adds serialised transaction in hex, e.g. from another emulation, to evil block
+func (rm *RuscoinMngr) EvilImportTx(value string, l EmuLogger) (int, error) {
+	data, err := ruscoin.StringToBytes(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return 0, fmt.Errorf("Evil: transaction is not hex string")
+	}
+	t, err := ruscoin.ParseTransaction(data)
+	if err != nil {
+		return 0, fmt.Errorf("Evil: %s", err)
+	}
+	l.Evil("Evil: replaying imported transaction")
+	return rm.evilReplay(*t, l)
+}
+
+// Spent inputs of the transaction are bound to unspent main node utxo of the same address
+// and amount, used output ids are renewed: without replay protection utxo ids are not
+// signed, so the sign stays valid
+func (rm *RuscoinMngr) evilReplay(t ruscoin.Transaction, l EmuLogger) (int, error) {
+	if rm.EvilBlock == nil {
+		if _, err := rm.EvilSteal(); err != nil {
+			return 0, err
+		}
+	}
+	m := rm.GetSetMainNode()
+	if m == nil {
+		return 0, fmt.Errorf("Evil: no main node set")
+	}
+	used := map[string]bool{}
+	for _, et := range rm.EvilBlock.Body.Transactions {
+		for id := range et.InputUtxo {
+			used[id] = true
+		}
+	}
+	inputs := ruscoin.NewUtxoList()
+	rebound := 0
+	for id, u := range t.InputUtxo.SortedItems() {
+		if m.Utxo.Contains(ruscoin.UtxoList{id: u}) && !used[id] {
+			inputs.Put(id, u.Addr, u.Amount)
+			used[id] = true
+			continue
+		}
+		for mid, mu := range m.Utxo.SortedItems() {
+			if mu == u && !used[mid] {
+				id = mid
+				rebound++
+				break
+			}
+		}
+		inputs.Put(id, u.Addr, u.Amount)
+		used[id] = true
+	}
+	t.InputUtxo = inputs
+	outputs := ruscoin.NewUtxoList()
+	renewed := 0
+	for id, u := range t.OutputUtxo.SortedItems() {
+		if _, spent := m.Spent[id]; spent || m.Utxo.CheckId(id) {
+			outputs.NewRecord(u.Addr, u.Amount)
+			renewed++
+			continue
+		}
+		outputs.Put(id, u.Addr, u.Amount)
+	}
+	t.OutputUtxo = outputs
+	i := rm.EvilBlock.AddTransaction(t)
+	if rebound > 0 {
+		l.Evil("Evil: %d spent inputs rebound to unspent utxo of the same owner and amount", rebound)
+	}
+	if renewed > 0 {
+		l.Evil("Evil: %d used output ids renewed", renewed)
+	}
+	l.Evil("Evil: replayed transaction added as evil transaction %d", i)
+	return i, nil
+}
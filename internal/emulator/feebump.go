@@ -0,0 +1,70 @@
+package emulator
+
+import (
+	"fmt"
+	"slices"
+
+	"myruscoint/internal/ruscoin"
+)
+
+// Fee bump modes
+const (
+	// Replace-by-fee: conflicting transaction with the same inputs and higher fee
+	BUMP_RBF = "rbf"
+	// Child-pays-for-parent: child spending unconfirmed output pays fee for both
+	BUMP_CPFP = "cpfp"
+)
+
+var BumpModes = []string{BUMP_RBF, BUMP_CPFP}
+
+// Unconfirmed transactions in wallet entry node mempool which spend wallet utxo or, if
+// incoming is set, pay to the wallet. The latest first
+func (rm *RuscoinMngr) PendingTransactions(w *ruscoin.Wallet, incoming bool) []ruscoin.Transaction {
+	n := rm.EntryNode(w)
+	if n == nil {
+		return nil
+	}
+	res := []ruscoin.Transaction{}
+	for _, t := range slices.Backward(n.Mempool) {
+		spends := len(w.OwnUtxo(t.InputUtxo)) > 0
+		pays := len(w.OwnUtxo(t.OutputUtxo)) > 0
+		if spends || incoming && pays {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Bumps the latest unconfirmed transaction of the wallet so it pays fee. RBF replaces the
+// transaction sent by the wallet, CPFP spends wallet output of sent or received one
+func (rm *RuscoinMngr) BumpFee(w *ruscoin.Wallet, mode string, fee int, l EmuLogger) (*ruscoin.Transaction, error) {
+	var t *ruscoin.Transaction
+	var err error
+	switch mode {
+	case BUMP_RBF:
+		pending := rm.PendingTransactions(w, false)
+		if len(pending) == 0 {
+			return nil, fmt.Errorf("BumpFee: wallet %s has no unconfirmed transactions", w.Name)
+		}
+		t, err = w.BumpFee(&pending[0], fee)
+	case BUMP_CPFP:
+		pending := rm.PendingTransactions(w, true)
+		i := slices.IndexFunc(pending, func(p ruscoin.Transaction) bool {
+			return len(w.OwnUtxo(p.OutputUtxo)) > 0
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("BumpFee: wallet %s has no unconfirmed outputs", w.Name)
+		}
+		t, err = w.ChildPays(&pending[i], fee)
+	default:
+		return nil, fmt.Errorf("BumpFee: unknown mode %s", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := rm.SubmitTransaction(w, t, l); err != nil {
+		return nil, err
+	}
+	l.OK("Wallet [%s]: fee bumped to %d by %s", w.Name, fee, mode)
+	return t, nil
+}
@@ -0,0 +1,166 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+	"slices"
+	"strings"
+)
+
+// Settings of headless emulation run
+type SimConfig struct {
+	Nodes   int
+	Wallets int
+	Ticks   int
+	// Random transactions sent to the main node before every tick
+	TxPerTick int
+	// Max fee of random transactions
+	MaxFee int
+	// Tamper attack kind, empty for no attack
+	Attack string
+	// Attack is made every AttackEvery tick
+	AttackEvery int
+	// Seed of deterministic mode, 0 - non deterministic
+	Seed int64
+	// Hash power of selfish miner node, 0 for no selfish miner. Honest nodes have power 1
+	SelfishPower int
+	// Share of honest nodes which receive selfish miner block first in a tie race
+	Gamma float64
+	// Hash power of double-spend attacker node, 0 for no attack
+	DoubleSpendPower int
+	// Confirmations the double-spend victim waits for
+	Confirmations int
+	// Behaviours of nodes Node1, Node2... as kind or kind:param, other nodes are honest
+	Behaviours []string
+}
+
+// Coins of double-spend attacker in genesis block and payment to the victim
+const (
+	SIM_DOUBLE_SPEND_ALLOC  = 100
+	SIM_DOUBLE_SPEND_AMOUNT = 50
+)
+
+func (c SimConfig) Validate() error {
+	if c.Nodes < 1 {
+		return fmt.Errorf("Simulation: at least one node required")
+	}
+	if c.Wallets < 0 || c.Ticks < 0 || c.TxPerTick < 0 || c.MaxFee < 0 {
+		return fmt.Errorf("Simulation: wallets, ticks, transactions and fee must not be negative")
+	}
+	if c.Attack != "" {
+		if !slices.Contains(AttackKinds, c.Attack) {
+			return fmt.Errorf("Simulation: unknown attack %s", c.Attack)
+		}
+		if c.AttackEvery < 1 {
+			return fmt.Errorf("Simulation: attack period must be positive")
+		}
+	}
+	if c.SelfishPower < 0 || c.SelfishPower > HASH_POWER_MAX {
+		return fmt.Errorf("Simulation: selfish miner hash power must be in range 0..%d", HASH_POWER_MAX)
+	}
+	if c.Gamma < 0 || c.Gamma > 1 {
+		return fmt.Errorf("Simulation: gamma must be in range 0..1")
+	}
+	if c.DoubleSpendPower < 0 || c.DoubleSpendPower > HASH_POWER_MAX {
+		return fmt.Errorf("Simulation: double-spend attacker hash power must be in range 0..%d", HASH_POWER_MAX)
+	}
+	if c.DoubleSpendPower > 0 && c.SelfishPower > 0 {
+		return fmt.Errorf("Simulation: selfish mining and double-spend can not run together")
+	}
+	if c.DoubleSpendPower > 0 && c.Confirmations < 1 {
+		return fmt.Errorf("Simulation: confirmations must be positive")
+	}
+	if len(c.Behaviours) > c.Nodes {
+		return fmt.Errorf("Simulation: %d behaviours for %d nodes", len(c.Behaviours), c.Nodes)
+	}
+	for _, b := range c.Behaviours {
+		if kind, _, _ := strings.Cut(b, ":"); !slices.Contains(BehaviourKinds, kind) {
+			return fmt.Errorf("Simulation: unknown behaviour %s", kind)
+		}
+	}
+	return nil
+}
+
+// Creates emulation with c.Nodes nodes and c.Wallets user wallets and runs c.Ticks ticks
+// without web server. Returns emulation state after the last tick
+func RunHeadless(c SimConfig, l EmuLogger) (*RuscoinMngr, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	rm := NewRuscoinMngr().WithSeed(c.Seed)
+	for i := 1; i <= c.Nodes; i++ {
+		if _, err := rm.NewNode(fmt.Sprintf("Node%d", i)); err != nil {
+			return nil, err
+		}
+	}
+	for i := 1; i <= c.Wallets; i++ {
+		if _, err := rm.NewWallet(fmt.Sprintf("User%d", i)); err != nil {
+			return nil, err
+		}
+	}
+	for i, kb := range c.Behaviours {
+		kind, param, _ := strings.Cut(kb, ":")
+		b, err := rm.NewBehaviour(kind, param)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := rm.NodeByName(fmt.Sprintf("Node%d", i+1))
+		rm.SetBehaviour(n.Id, b)
+	}
+	var selfish *ruscoin.Node
+	if c.SelfishPower > 0 {
+		n, err := rm.NewNode("Selfish")
+		if err != nil {
+			return nil, err
+		}
+		n.HashPower = c.SelfishPower
+		selfish = n
+	}
+	var doubleSpender *ruscoin.Node
+	var victim *ruscoin.Wallet
+	if c.DoubleSpendPower > 0 {
+		n, err := rm.NewNode("Attacker")
+		if err != nil {
+			return nil, err
+		}
+		n.HashPower = c.DoubleSpendPower
+		rm.GenesisAlloc = map[string]int{n.Wallet.Addr: SIM_DOUBLE_SPEND_ALLOC}
+		if victim, err = rm.NewWallet("Victim"); err != nil {
+			return nil, err
+		}
+		doubleSpender = n
+	}
+	var attacker *ruscoin.Wallet
+	if c.Attack != "" {
+		w, err := rm.NewWallet("Evil")
+		if err != nil {
+			return nil, err
+		}
+		attacker = w
+	}
+
+	for k := 0; k < c.Ticks; k++ {
+		if rm.Tick > 0 {
+			rm.RandomTransactions(c.TxPerTick, c.MaxFee, l)
+			if attacker != nil && rm.Tick%c.AttackEvery == 0 {
+				if _, err := rm.EvilTamperAttack(c.Attack, attacker, l); err != nil {
+					l.Error(err.Error())
+				}
+			}
+		}
+		if err := rm.RunTick(l); err != nil {
+			return rm, err
+		}
+		if selfish != nil && rm.Selfish == nil {
+			if _, err := rm.StartSelfishMining(selfish.Id, c.Gamma); err != nil {
+				return rm, err
+			}
+		}
+		if doubleSpender != nil && rm.DoubleSpend == nil {
+			if _, err := rm.StartDoubleSpend(doubleSpender.Id, victim.Addr, SIM_DOUBLE_SPEND_AMOUNT, c.Confirmations, l); err != nil {
+				return rm, err
+			}
+		}
+	}
+	return rm, nil
+}
@@ -0,0 +1,55 @@
+package emulator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"myruscoint/internal/ruscoin"
+)
+
+// Transactions of the wallet in the chain of its node, oldest first
+func (rm *RuscoinMngr) WalletHistory(w *ruscoin.Wallet) ([]ruscoin.HistoryEntry, error) {
+	n := rm.WalletNode(w)
+	if n == nil {
+		return nil, fmt.Errorf("RuscoinMngr: no node with history of wallet [%s]", w.Name)
+	}
+	return n.WalletHistory(w), nil
+}
+
+// Wallet names of history entry counterparties, comma separated
+func (rm *RuscoinMngr) Counterparties(e *ruscoin.HistoryEntry) string {
+	names := make([]string, len(e.Counterparties))
+	for i, a := range e.Counterparties {
+		names[i] = rm.walletLabel(a)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Writes wallet history as CSV with header row
+func (rm *RuscoinMngr) WriteWalletHistoryCSV(out io.Writer, w *ruscoin.Wallet) error {
+	h, err := rm.WalletHistory(w)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(out)
+	cw.Write([]string{"height", "time", "sign", "counterparty", "received", "sent", "fee", "amount", "balance"})
+	for _, e := range h {
+		cw.Write([]string{
+			strconv.Itoa(e.Height),
+			e.Time.UTC().Format(time.RFC3339),
+			e.Tx.SignString(),
+			rm.Counterparties(&e),
+			strconv.Itoa(e.Received),
+			strconv.Itoa(e.Sent),
+			strconv.Itoa(e.Fee),
+			strconv.Itoa(e.Amount()),
+			strconv.Itoa(e.Balance),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,59 @@
+package emulator
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	glb "myruscoint/internal/globals"
+)
+
+// Receives log messages and state change notifications from emulation operations
+type EmuLogger interface {
+	Info(msg string, a ...any)
+	Error(msg string, a ...any)
+	OK(msg string, a ...any)
+	Evil(msg string, a ...any)
+	// Node chain, coinbase or wallet has changed
+	NodeUpdate(id string)
+	// New miner node is selected
+	MinerUpdate()
+}
+
+// Logger which drops everything
+type NopLogger struct{}
+
+func (NopLogger) Info(msg string, a ...any)  {}
+func (NopLogger) Error(msg string, a ...any) {}
+func (NopLogger) OK(msg string, a ...any)    {}
+func (NopLogger) Evil(msg string, a ...any)  {}
+func (NopLogger) NodeUpdate(id string)       {}
+func (NopLogger) MinerUpdate()               {}
+
+// Plain text logger for headless runs
+type TextLogger struct {
+	W io.Writer
+}
+
+func (l TextLogger) Info(msg string, a ...any)  { l.write("INF", msg, a...) }
+func (l TextLogger) Error(msg string, a ...any) { l.write("ERR", msg, a...) }
+func (l TextLogger) OK(msg string, a ...any)    { l.write("OKK", msg, a...) }
+func (l TextLogger) Evil(msg string, a ...any)  { l.write("EVL", msg, a...) }
+func (l TextLogger) NodeUpdate(id string)       {}
+func (l TextLogger) MinerUpdate()               {}
+
+func (l TextLogger) write(lvl, msg string, a ...any) {
+	fmt.Fprintf(l.W, "%s %s%s\n", lvl, time.Now().Format(glb.LOG_DATE_FORMAT), fmt.Sprintf(msg, a...))
+}
+
+// Sends emulation log and node updates to web clients via RSS
+type rssLogger struct {
+	wb *EmulatorWeb
+}
+
+func (l rssLogger) Info(msg string, a ...any)  { l.wb.RssLogInfoSend(msg, a...) }
+func (l rssLogger) Error(msg string, a ...any) { l.wb.RssLogErrorSend(msg, a...) }
+func (l rssLogger) OK(msg string, a ...any)    { l.wb.RssLogOKSend(msg, a...) }
+func (l rssLogger) Evil(msg string, a ...any)  { l.wb.RssLogEvilSend(msg, a...) }
+func (l rssLogger) NodeUpdate(id string)       { l.wb.RssNodeAllUpdates(id) }
+func (l rssLogger) MinerUpdate()               { l.wb.RssSendMinerSelect() }
@@ -0,0 +1,161 @@
+package emulator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"myruscoint/internal/ruscoin"
+)
+
+// Shared m-of-n account of wallets, e.g. escrow of buyer, seller and arbiter
+type MultisigAccount struct {
+	Name string
+	Ms   *ruscoin.Multisig
+	// Names of wallets whose keys are in the script
+	Owners []string
+	// Spend waiting for cosigner signs, nil if there is none
+	Pending *ruscoin.Transaction
+	// Wallets which signed pending spend
+	Signed []string
+}
+
+func (a *MultisigAccount) Addr() string {
+	return a.Ms.Addr()
+}
+
+// Creates m-of-n account of the wallets. Script lists the key of every wallet address
+func (rm *RuscoinMngr) NewMultisig(name string, m int, owners []*ruscoin.Wallet) (*MultisigAccount, error) {
+	if name == "" {
+		return nil, fmt.Errorf("RuscoinMngr: multisig name is empty")
+	}
+	if _, ok := rm.Multisigs[name]; ok {
+		return nil, fmt.Errorf("RuscoinMngr: multisig [%s] already exists", name)
+	}
+	keys := [][]byte{}
+	names := []string{}
+	for _, w := range owners {
+		if slices.Contains(names, w.Name) {
+			return nil, fmt.Errorf("RuscoinMngr: wallet [%s] is listed twice", w.Name)
+		}
+		keys = append(keys, w.S.PubKey.Raw())
+		names = append(names, w.Name)
+	}
+	ms, err := ruscoin.NewMultisig(m, keys)
+	if err != nil {
+		return nil, err
+	}
+	a := &MultisigAccount{Name: name, Ms: ms, Owners: names}
+	rm.Multisigs[name] = a
+	return a, nil
+}
+
+func (rm *RuscoinMngr) MultisigByName(name string) (*MultisigAccount, error) {
+	a, ok := rm.Multisigs[name]
+	if !ok {
+		return nil, fmt.Errorf("RuscoinMngr: multisig [%s] not found", name)
+	}
+	return a, nil
+}
+
+func (rm *RuscoinMngr) MultisigByAddr(addr string) (*MultisigAccount, bool) {
+	for _, a := range rm.Multisigs {
+		if a.Addr() == addr {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// Multisig accounts sorted by name
+func (rm *RuscoinMngr) MultisigList() []*MultisigAccount {
+	res := []*MultisigAccount{}
+	for _, name := range slices.Sorted(maps.Keys(rm.Multisigs)) {
+		res = append(res, rm.Multisigs[name])
+	}
+	return res
+}
+
+// Utxo of the account in the public chain
+func (rm *RuscoinMngr) MultisigUtxo(a *MultisigAccount) ruscoin.UtxoList {
+	n := rm.PublicNode()
+	if n == nil {
+		return ruscoin.NewUtxoList()
+	}
+	return n.Utxo.FilterAddress(a.Addr())
+}
+
+// Proposes spend of all account utxo paying amount to address, change goes back to the
+// account. Spend waits for signs of cosigners, previous unfinished spend is dropped
+func (rm *RuscoinMngr) ProposeMultisigSpend(a *MultisigAccount, to string, amount, fee int) (*ruscoin.Transaction, error) {
+	if w, ok := rm.Wallets[to]; ok {
+		to = w.ReceiveAddr()
+	}
+	t, err := ruscoin.NewMultisigTransaction(a.Ms, rm.MultisigUtxo(a), []ruscoin.Payment{{Addr: to, Amount: amount}}, fee)
+	if err != nil {
+		return nil, err
+	}
+	a.Pending = t
+	a.Signed = nil
+	return t, nil
+}
+
+// Adds wallet sign to the pending spend of the account. Spend with enough signs is sent to
+// the network through the wallet entry node. Returns whether the spend was sent
+func (rm *RuscoinMngr) SignMultisig(a *MultisigAccount, w *ruscoin.Wallet, l EmuLogger) (bool, error) {
+	if a.Pending == nil {
+		return false, fmt.Errorf("Multisig [%s]: no pending spend", a.Name)
+	}
+	if !slices.Contains(a.Owners, w.Name) {
+		return false, fmt.Errorf("Multisig [%s]: wallet [%s] is not a cosigner", a.Name, w.Name)
+	}
+	added, err := w.SignMultisig(a.Pending)
+	if err != nil {
+		return false, err
+	}
+	if added == 0 {
+		return false, fmt.Errorf("Multisig [%s]: wallet [%s] has already signed", a.Name, w.Name)
+	}
+	a.Signed = append(a.Signed, w.Name)
+	l.Info("Multisig [%s]: %s signed, %d of %d signs", a.Name, w.Name, len(a.Signed), a.Ms.M)
+	if !a.Pending.MultisigComplete() {
+		return false, nil
+	}
+	if err := rm.SubmitMultisig(a, l); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Sends pending spend of the account to the network whether it has enough signs or not
+func (rm *RuscoinMngr) SubmitMultisig(a *MultisigAccount, l EmuLogger) error {
+	if a.Pending == nil {
+		return fmt.Errorf("Multisig [%s]: no pending spend", a.Name)
+	}
+	from := a.Owners[0]
+	if len(a.Signed) > 0 {
+		from = a.Signed[len(a.Signed)-1]
+	}
+	w, err := rm.WalletByName(from)
+	if err != nil {
+		return err
+	}
+	if err := rm.SubmitTransaction(w, a.Pending, l); err != nil {
+		return fmt.Errorf("Multisig [%s]: spend rejected: %s", a.Name, err)
+	}
+	l.OK("Multisig [%s]: spend with %d signs sent", a.Name, len(a.Signed))
+	a.Pending = nil
+	a.Signed = nil
+	return nil
+}
+
+// Puts pending spend of the account into the evil block, so that nodes check it in a block
+func (rm *RuscoinMngr) EvilAddMultisig(a *MultisigAccount) (int, error) {
+	if rm.EvilBlock == nil {
+		return 0, fmt.Errorf("Evil: evil block not set. Steal new block.")
+	}
+	if a.Pending == nil {
+		return 0, fmt.Errorf("Multisig [%s]: no pending spend", a.Name)
+	}
+	return rm.EvilBlock.AddTransaction(*a.Pending), nil
+}
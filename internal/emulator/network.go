@@ -0,0 +1,310 @@
+package emulator
+
+import (
+	"fmt"
+	"maps"
+	"myruscoint/internal/ruscoin"
+	"slices"
+	"strings"
+)
+
+// Network partition. Nodes of different groups can not exchange blocks
+type Partition struct {
+	// Node id to group number
+	Groups map[string]int
+	// Tick when partition heals, 0 - only manual heal
+	Until int
+}
+
+// Link between two nodes which does not pass blocks
+type CutLink struct {
+	A, B *ruscoin.Node
+	// Tick when link is restored, 0 - only manual heal
+	Until int
+}
+
+// Eclipse attack: victim neighbours are replaced by attacker nodes, which are
+// isolated from honest network and feed the victim their own chain
+type Eclipse struct {
+	Victim    *ruscoin.Node
+	Attackers []*ruscoin.Node
+	// Victim neighbours before the attack
+	saved   []*ruscoin.Node
+	rewired bool
+}
+
+// Adds link between nodes a and b. Once any link exists blocks spread only along links
+func (rm *RuscoinMngr) Link(a, b string) error {
+	na, nb, err := rm.nodePair(a, b)
+	if err != nil {
+		return err
+	}
+	na.AddNeighbour(nb)
+	nb.AddNeighbour(na)
+	return nil
+}
+
+// Removes link between nodes a and b
+func (rm *RuscoinMngr) Unlink(a, b string) error {
+	na, nb, err := rm.nodePair(a, b)
+	if err != nil {
+		return err
+	}
+	na.RemoveNeighbour(nb.Id)
+	nb.RemoveNeighbour(na.Id)
+	return nil
+}
+
+// Cuts connection between nodes a and b for ticks ticks, 0 - until healed
+func (rm *RuscoinMngr) CutLink(a, b string, ticks int) error {
+	na, nb, err := rm.nodePair(a, b)
+	if err != nil {
+		return err
+	}
+	if ticks < 0 {
+		return fmt.Errorf("Network: ticks must not be negative")
+	}
+	if rm.Cuts == nil {
+		rm.Cuts = make(map[string]CutLink)
+	}
+	rm.Cuts[linkKey(na, nb)] = CutLink{A: na, B: nb, Until: rm.until(ticks)}
+	return nil
+}
+
+// Splits network into groups of node ids for ticks ticks, 0 - until healed.
+// Nodes not listed in any group make one more group
+func (rm *RuscoinMngr) SplitNetwork(groups [][]string, ticks int) error {
+	if rm.Partition != nil {
+		return fmt.Errorf("Network: network is already split")
+	}
+	if ticks < 0 {
+		return fmt.Errorf("Network: ticks must not be negative")
+	}
+	p := &Partition{Groups: make(map[string]int), Until: rm.until(ticks)}
+	for i, g := range groups {
+		for _, id := range g {
+			if _, err := rm.GetNode(id); err != nil {
+				return err
+			}
+			if _, ok := p.Groups[id]; ok {
+				return fmt.Errorf("Network: node %s is in several groups", rm.Nodes[id].Name)
+			}
+			p.Groups[id] = i + 1
+		}
+	}
+	if len(p.Groups) == 0 || len(p.Groups) == len(rm.Nodes) && len(groups) < 2 {
+		return fmt.Errorf("Network: at least two groups required")
+	}
+	rm.Partition = p
+	return nil
+}
+
+// Surrounds victim by attacker nodes for ticks ticks, 0 - until healed
+func (rm *RuscoinMngr) StartEclipse(victim string, attackers []string, ticks int) error {
+	if rm.Partition != nil {
+		return fmt.Errorf("Network: network is already split")
+	}
+	v, err := rm.GetNode(victim)
+	if err != nil {
+		return err
+	}
+	if len(attackers) == 0 {
+		return fmt.Errorf("Network: eclipse needs at least one attacker node")
+	}
+	e := &Eclipse{Victim: v}
+	group := []string{v.Id}
+	for _, id := range attackers {
+		a, err := rm.GetNode(id)
+		if err != nil {
+			return err
+		}
+		if a == v {
+			return fmt.Errorf("Network: victim can not be an attacker")
+		}
+		e.Attackers = append(e.Attackers, a)
+		group = append(group, a.Id)
+	}
+	if len(group) == len(rm.Nodes) {
+		return fmt.Errorf("Network: at least one honest node must stay outside the eclipse")
+	}
+	if err = rm.SplitNetwork([][]string{group}, ticks); err != nil {
+		return err
+	}
+	// in full mesh partition is enough, otherwise victim links lead only to attackers
+	if !rm.FullMesh() {
+		for _, id := range slices.Sorted(maps.Keys(v.Neighbours)) {
+			e.saved = append(e.saved, v.Neighbours[id])
+			rm.Unlink(v.Id, id)
+		}
+		for _, a := range e.Attackers {
+			rm.Link(v.Id, a.Id)
+		}
+		e.rewired = true
+	}
+	rm.Eclipse = e
+	return nil
+}
+
+// Removes partition and all cut links, restores eclipse victim neighbours
+// and lets nodes reconcile their chains
+func (rm *RuscoinMngr) HealNetwork(l EmuLogger) {
+	rm.Cuts = nil
+	rm.healPartition()
+	rm.Reconcile(l)
+}
+
+func (rm *RuscoinMngr) healPartition() {
+	rm.Partition = nil
+	e := rm.Eclipse
+	if e == nil {
+		return
+	}
+	if !e.rewired {
+		rm.Eclipse = nil
+		return
+	}
+	for _, a := range e.Attackers {
+		rm.Unlink(e.Victim.Id, a.Id)
+	}
+	for _, n := range e.saved {
+		if _, ok := rm.Nodes[n.Id]; ok {
+			rm.Link(e.Victim.Id, n.Id)
+		}
+	}
+	rm.Eclipse = nil
+}
+
+// Heals cut links and partition whose time has come. Called after tick, returns if anything healed
+func (rm *RuscoinMngr) healExpired(l EmuLogger) bool {
+	healed := false
+	for _, k := range slices.Sorted(maps.Keys(rm.Cuts)) {
+		c := rm.Cuts[k]
+		if c.Until > 0 && rm.Tick >= c.Until {
+			delete(rm.Cuts, k)
+			l.OK("Network: link %s - %s restored", c.A.Name, c.B.Name)
+			healed = true
+		}
+	}
+	if p := rm.Partition; p != nil && p.Until > 0 && rm.Tick >= p.Until {
+		rm.healPartition()
+		l.OK("Network: partition healed")
+		healed = true
+	}
+	if healed {
+		rm.Reconcile(l)
+	}
+	return healed
+}
+
+// Every node relays its chain to reachable nodes, so connected nodes end up on the longest chain
+func (rm *RuscoinMngr) Reconcile(l EmuLogger) {
+	reorg := false
+	for _, id := range rm.NodeIds() {
+		if n := rm.Nodes[id]; !n.Offline && len(n.BlockChain) > 0 {
+			reorg = rm.propagate(n, l) || reorg
+		}
+	}
+	if reorg {
+		rm.SyncWallets(l)
+	}
+}
+
+// Spreads chain of src through the network. Node relays chain further only if it switched
+// to it or already has the same tip. Returns if any node reorganised its chain
+func (rm *RuscoinMngr) propagate(src *ruscoin.Node, l EmuLogger) bool {
+	reorg := false
+	visited := map[string]bool{src.Id: true}
+	queue := []*ruscoin.Node{src}
+	tip := src.GetLastBlock()
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, v := range rm.peers(u) {
+			if visited[v.Id] || v.Offline {
+				continue
+			}
+			visited[v.Id] = true
+			if _, removed := rm.deliverChain(src.BlockChain, v, l); removed > 0 {
+				reorg = true
+			}
+			if b := v.GetLastBlock(); b != nil && tip != nil && b.HashString() == tip.HashString() {
+				queue = append(queue, v)
+			}
+		}
+	}
+	return reorg
+}
+
+// Nodes which receive blocks from n: all other nodes in full mesh mode
+// (no links defined), otherwise neighbours. Cut links and partition are excluded
+func (rm *RuscoinMngr) peers(n *ruscoin.Node) []*ruscoin.Node {
+	res := []*ruscoin.Node{}
+	mesh := rm.FullMesh()
+	for _, id := range rm.NodeIds() {
+		if id == n.Id {
+			continue
+		}
+		if _, ok := n.Neighbours[id]; !mesh && !ok {
+			continue
+		}
+		if m := rm.Nodes[id]; rm.Connected(n, m) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// No node has links, every node talks to every other
+func (rm *RuscoinMngr) FullMesh() bool {
+	for _, n := range rm.Nodes {
+		if len(n.Neighbours) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Nodes can exchange blocks: link between them is not cut and they are in the same group
+func (rm *RuscoinMngr) Connected(a, b *ruscoin.Node) bool {
+	if _, ok := rm.Cuts[linkKey(a, b)]; ok {
+		return false
+	}
+	return rm.Partition == nil || rm.Partition.Groups[a.Id] == rm.Partition.Groups[b.Id]
+}
+
+// Partition group of the node, 0 for nodes not listed in any group
+func (rm *RuscoinMngr) NodeGroup(id string) int {
+	if rm.Partition == nil {
+		return 0
+	}
+	return rm.Partition.Groups[id]
+}
+
+func (rm *RuscoinMngr) nodePair(a, b string) (*ruscoin.Node, *ruscoin.Node, error) {
+	na, err := rm.GetNode(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	nb, err := rm.GetNode(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if na == nb {
+		return nil, nil, fmt.Errorf("Network: node can not be linked to itself")
+	}
+	return na, nb, nil
+}
+
+// Tick number to heal after ticks ticks, 0 if ticks is 0
+func (rm *RuscoinMngr) until(ticks int) int {
+	if ticks == 0 {
+		return 0
+	}
+	return rm.Tick + ticks
+}
+
+func linkKey(a, b *ruscoin.Node) string {
+	ids := []string{a.Id, b.Id}
+	slices.Sort(ids)
+	return strings.Join(ids, "-")
+}
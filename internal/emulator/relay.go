@@ -0,0 +1,63 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+)
+
+// Node where wallet transactions enter the network: node the wallet is attached to or node
+// owning the wallet if it is online, otherwise the main node
+func (rm *RuscoinMngr) EntryNode(w *ruscoin.Wallet) *ruscoin.Node {
+	if n, ok := rm.Nodes[w.NodeId]; ok && !n.Offline {
+		return n
+	}
+	for _, id := range rm.NodeIds() {
+		if n := rm.Nodes[id]; n.Wallet == w && !n.Offline {
+			return n
+		}
+	}
+	return rm.MainNode()
+}
+
+// Puts wallet transaction into entry node mempool and relays it through the network
+func (rm *RuscoinMngr) SubmitTransaction(w *ruscoin.Wallet, t *ruscoin.Transaction, l EmuLogger) error {
+	n := rm.EntryNode(w)
+	if n == nil {
+		return fmt.Errorf("RuscoinMngr: no node to send transaction to")
+	}
+	if err := n.AddMempoolTransaction(*t); err != nil {
+		return err
+	}
+	rm.trackTransaction(w, t)
+	rm.relayTransaction(n, t, false, l)
+	return nil
+}
+
+// Spreads transaction from src mempool to mempools of reachable nodes. Node relays transaction
+// further only if it accepted it. Sybil nodes intercept selected transactions unless bypass is set.
+// Returns number of nodes which accepted transaction
+func (rm *RuscoinMngr) relayTransaction(src *ruscoin.Node, t *ruscoin.Transaction, bypass bool, l EmuLogger) int {
+	accepted := 0
+	visited := map[string]bool{src.Id: true}
+	queue := []*ruscoin.Node{src}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		if u != src && !bypass && rm.Sybil.intercepts(u, t) {
+			rm.Sybil.hold(rm, u, t, l)
+			continue
+		}
+		for _, v := range rm.peers(u) {
+			if visited[v.Id] || v.Offline {
+				continue
+			}
+			visited[v.Id] = true
+			if err := v.AddMempoolTransaction(*t); err != nil {
+				continue
+			}
+			accepted++
+			queue = append(queue, v)
+		}
+	}
+	return accepted
+}
@@ -1,9 +1,13 @@
 package emulator
 
 import (
+	"encoding/hex"
 	"fmt"
+	"maps"
 	"math/rand"
 	"myruscoint/internal/ruscoin"
+	"slices"
+	"time"
 )
 
 type RuscoinMngr struct {
@@ -12,22 +16,82 @@ type RuscoinMngr struct {
 	mainNode  *ruscoin.Node
 	EvilBlock *ruscoin.Block
 	Tick      int
+	// Pause between node operations during tick
+	OpPause time.Duration
+	// Pauses of the last operations, slept by the caller once locks are released
+	pauseDue time.Duration
+	// Blocks rejected by nodes during emulation
+	Rejected []RejectedBlock
+	// Coins sent to wallet addresses in genesis block
+	GenesisAlloc map[string]int
+	// Randomness of emulation: miner choice, random traffic
+	Rand *rand.Rand
+	// Selfish mining attacker, nil if attack is not running
+	Selfish *SelfishMiner
+	// Last double-spend attack, running or finished
+	DoubleSpend *DoubleSpendAttack
+	// Network split, nil if all nodes can talk
+	Partition *Partition
+	// Cut links by link key
+	Cuts map[string]CutLink
+	// Eclipse attack, nil if not running
+	Eclipse *Eclipse
+	// Sybil attack, nil if not running
+	Sybil *SybilAttack
+	// Node id to owner of attacker controlled nodes. Honest nodes are not listed
+	Owners map[string]string
+	// Node id to behaviour of not honest nodes
+	Behaviours map[string]Behaviour
+	// Wait times of submitted wallet transactions, pending and recently included
+	TxWaits []*TxWait
+	// Multisig accounts by name
+	Multisigs map[string]*MultisigAccount
+}
+
+const HASH_POWER_MAX = 1000
+
+type RejectedBlock struct {
+	Tick   int
+	Node   string
+	Height int
+	Hash   string
+	Reason string
 }
 
 func NewRuscoinMngr() *RuscoinMngr {
 	return &RuscoinMngr{
-		Nodes:    make(map[string]*ruscoin.Node),
-		Wallets:  make(map[string]*ruscoin.Wallet),
-		mainNode: nil,
+		Nodes:      make(map[string]*ruscoin.Node),
+		Wallets:    make(map[string]*ruscoin.Wallet),
+		Owners:     make(map[string]string),
+		Behaviours: make(map[string]Behaviour),
+		Multisigs:  make(map[string]*MultisigAccount),
+		mainNode:   nil,
+		Rand:       newRand(0),
 	}
 }
 
-func DefaultRuscoinMngr() *RuscoinMngr {
-	rm := &RuscoinMngr{
-		Nodes:    make(map[string]*ruscoin.Node),
-		Wallets:  make(map[string]*ruscoin.Wallet),
-		mainNode: nil,
+// Resets emulation and ruscoin random sources with seed. Zero seed means non deterministic mode.
+// Must be called before nodes and wallets are created
+func (rm *RuscoinMngr) WithSeed(seed int64) *RuscoinMngr {
+	ruscoin.SetSeed(seed)
+	rm.Rand = newRand(seed)
+	return rm
+}
+
+func newRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
 	}
+	return rand.New(rand.NewSource(seed))
+}
+
+func (rm *RuscoinMngr) WithOpPause(d time.Duration) *RuscoinMngr {
+	rm.OpPause = d
+	return rm
+}
+
+func DefaultRuscoinMngr() *RuscoinMngr {
+	rm := NewRuscoinMngr().WithSeed(ruscoin.SEED)
 	for _, name := range []string{"Node1", "Node2", "Node3"} {
 		rm.NewNode(name)
 	}
@@ -39,16 +103,47 @@ func (rm *RuscoinMngr) SelectMainNode() *ruscoin.Node {
 	if rm.mainNode != nil {
 		rm.mainNode.BlockCandidate = nil
 	}
-	l := len(rm.Nodes)
-	ids := make([]string, 0, l)
-	for k := range rm.Nodes {
-		ids = append(ids, k)
+	// miner is selected proportionally to hash power
+	ids := make([]string, 0, len(rm.Nodes))
+	total := 0
+	for _, k := range rm.NodeIds() {
+		if n := rm.Nodes[k]; !n.Offline && n.HashPower > 0 {
+			ids = append(ids, k)
+			total += n.HashPower
+		}
+	}
+	if len(ids) == 0 {
+		rm.mainNode = nil
+		return nil
+	}
+	r := rm.Rand.Intn(total)
+	for _, k := range ids {
+		if r -= rm.Nodes[k].HashPower; r < 0 {
+			rm.mainNode = rm.Nodes[k]
+			break
+		}
 	}
-	rm.mainNode = rm.Nodes[ids[rand.Intn(l)]]
 	rm.mainNode.NewBlockCandidate()
 	return rm.mainNode
 }
 
+// Sets node with given id as main node
+func (rm *RuscoinMngr) SetMainNode(id string) (*ruscoin.Node, error) {
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.Offline {
+		return nil, fmt.Errorf("RuscoinMngr: Node [%s] is offline", n.Name)
+	}
+	if rm.mainNode != nil {
+		rm.mainNode.BlockCandidate = nil
+	}
+	rm.mainNode = n
+	n.NewBlockCandidate()
+	return n, nil
+}
+
 func (rm *RuscoinMngr) GetSetMainNode() *ruscoin.Node {
 	if rm.mainNode != nil {
 		return rm.mainNode
@@ -70,6 +165,11 @@ func (rm *RuscoinMngr) NodeNames() []string {
 	return names
 }
 
+// Sorted ids of all nodes
+func (rm *RuscoinMngr) NodeIds() []string {
+	return slices.Sorted(maps.Keys(rm.Nodes))
+}
+
 func (rm *RuscoinMngr) NewWallet(name string) (*ruscoin.Wallet, error) {
 	w, err := ruscoin.NewWallet(name)
 	if err != nil {
@@ -83,27 +183,304 @@ func (rm *RuscoinMngr) AddWallet(w *ruscoin.Wallet) {
 	rm.Wallets[w.Addr] = w
 }
 
+// Adds wallet with key from passphrase protected keystore. Wallet scans the public chain
+func (rm *RuscoinMngr) ImportWallet(name string, keystore []byte, pass string) (*ruscoin.Wallet, error) {
+	w, err := ruscoin.ImportWallet(name, keystore, pass)
+	if err != nil {
+		return nil, err
+	}
+	return w, rm.addRestoredWallet(w)
+}
+
+// Adds HD wallet with keys from seed phrase, new random phrase if mnemonic is empty.
+// Wallet scans the public chain for coins of all its addresses
+func (rm *RuscoinMngr) NewHDWallet(name, mnemonic string) (*ruscoin.Wallet, error) {
+	var w *ruscoin.Wallet
+	var err error
+	if mnemonic == "" {
+		w, err = ruscoin.NewHDWallet(name)
+	} else {
+		w, err = ruscoin.RestoreHDWallet(name, mnemonic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, rm.addRestoredWallet(w)
+}
+
+// Replaces HD wallet with the one restored from its seed phrase. Wallet keeps its name
+// and node, utxo and addresses are recovered by rescan
+func (rm *RuscoinMngr) RecoverWallet(w *ruscoin.Wallet) (*ruscoin.Wallet, error) {
+	if !w.HD() {
+		return nil, fmt.Errorf("RuscoinMngr: wallet [%s] has no seed phrase", w.Name)
+	}
+	r, err := ruscoin.RestoreHDWallet(w.Name, w.Mnemonic())
+	if err != nil {
+		return nil, err
+	}
+	r.NodeId = w.NodeId
+	delete(rm.Wallets, w.Addr)
+	if err := rm.addRestoredWallet(r); err != nil {
+		rm.Wallets[w.Addr] = w
+		return nil, err
+	}
+	return r, nil
+}
+
+func (rm *RuscoinMngr) addRestoredWallet(w *ruscoin.Wallet) error {
+	if w.Name == "" {
+		return fmt.Errorf("RuscoinMngr: wallet has no name")
+	}
+	if o, ok := rm.Wallets[w.Addr]; ok {
+		return fmt.Errorf("RuscoinMngr: wallet [%s] with address %s already exists", o.Name, w.Addr)
+	}
+	rm.AddWallet(w)
+	if n := rm.WalletNode(w); n != nil {
+		w.Rescan(n)
+	}
+	return nil
+}
+
 func (rm *RuscoinMngr) NewNode(name string) (*ruscoin.Node, error) {
 	n, err := ruscoin.NewNode(name)
 	if err != nil {
 		return nil, err
 	}
 	rm.Nodes[n.Id] = n
+	n.Wallet.NodeId = n.Id
 	rm.AddWallet(n.Wallet)
 	return n, err
 }
 
-func (rm *RuscoinMngr) Mine() (*ruscoin.Block, error) {
-	b, err := rm.GetSetMainNode().Mine()
+func (rm *RuscoinMngr) GetNode(id string) (*ruscoin.Node, error) {
+	n, ok := rm.Nodes[id]
+	if !ok {
+		return nil, fmt.Errorf("RuscoinMngr: Node [%s] not found", id)
+	}
+	return n, nil
+}
+
+func (rm *RuscoinMngr) GetWallet(addr string) (*ruscoin.Wallet, error) {
+	w, ok := rm.Wallets[addr]
+	if !ok {
+		return nil, fmt.Errorf("RuscoinMngr: Wallet [%s] not found", addr)
+	}
+	return w, nil
+}
+
+func (rm *RuscoinMngr) NodeByName(name string) (*ruscoin.Node, error) {
+	for _, n := range rm.Nodes {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("RuscoinMngr: Node [%s] not found", name)
+}
+
+// Wallet owning the address, nil if there is none
+func (rm *RuscoinMngr) WalletOf(addr string) *ruscoin.Wallet {
+	if w, ok := rm.Wallets[addr]; ok {
+		return w
+	}
+	for _, a := range slices.Sorted(maps.Keys(rm.Wallets)) {
+		if w := rm.Wallets[a]; w.Owns(addr) {
+			return w
+		}
+	}
+	return nil
+}
+
+func (rm *RuscoinMngr) WalletByName(name string) (*ruscoin.Wallet, error) {
+	for _, w := range rm.Wallets {
+		if w.Name == name {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("RuscoinMngr: Wallet [%s] not found", name)
+}
+
+// Online node with the longest chain except node with given id
+func (rm *RuscoinMngr) BestPeer(exceptId string) *ruscoin.Node {
+	var best *ruscoin.Node
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		if id == exceptId || n.Offline {
+			continue
+		}
+		if best == nil || len(n.BlockChain) > len(best.BlockChain) {
+			best = n
+		}
+	}
+	return best
+}
+
+// Downloads missing blocks to the node from the best online peer. Returns number of added blocks
+func (rm *RuscoinMngr) SyncNode(id string) (int, error) {
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return 0, err
+	}
+	peer := rm.BestPeer(id)
+	if peer == nil {
+		return 0, nil
+	}
+	return n.SyncChain(peer)
+}
+
+func (rm *RuscoinMngr) RenameNode(id, name string) error {
+	if name == "" {
+		return fmt.Errorf("RuscoinMngr: node name is empty")
+	}
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return err
+	}
+	n.Name = name
+	return nil
+}
+
+// Removes node from emulation. Node's wallet stays, as it's coins are still on chain
+func (rm *RuscoinMngr) RemoveNode(id string) error {
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return err
+	}
+	delete(rm.Nodes, id)
+	delete(rm.Owners, id)
+	delete(rm.Behaviours, id)
+	for _, m := range rm.Nodes {
+		m.RemoveNeighbour(id)
+	}
+	if s := rm.Sybil; s != nil {
+		s.Nodes = slices.DeleteFunc(s.Nodes, func(m *ruscoin.Node) bool { return m == n })
+	}
+	if rm.Selfish != nil && rm.Selfish.Node == n {
+		rm.Selfish = nil
+	}
+	if rm.DoubleSpend.Running() && rm.DoubleSpend.Node == n {
+		rm.DoubleSpend = nil
+	}
+	for k, c := range rm.Cuts {
+		if c.A == n || c.B == n {
+			delete(rm.Cuts, k)
+		}
+	}
+	if e := rm.Eclipse; e != nil && (e.Victim == n || slices.Contains(e.Attackers, n)) {
+		rm.healPartition()
+	}
+	if rm.mainNode == n {
+		rm.mainNode = nil
+		rm.SelectMainNode()
+	}
+	return nil
+}
+
+// Crashed node stops mining and receiving blocks until restored
+func (rm *RuscoinMngr) CrashNode(id string) error {
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return err
+	}
+	n.Offline = true
+	n.BlockCandidate = nil
+	if rm.mainNode == n {
+		rm.mainNode = nil
+		rm.SelectMainNode()
+	}
+	return nil
+}
+
+// Brings crashed node back and syncs it's chain. Returns number of synced blocks
+func (rm *RuscoinMngr) RestoreNode(id string) (int, error) {
+	n, err := rm.GetNode(id)
 	if err != nil {
+		return 0, err
+	}
+	n.Offline = false
+	return rm.SyncNode(id)
+}
+
+func (rm *RuscoinMngr) RenameWallet(addr, name string) error {
+	if name == "" {
+		return fmt.Errorf("RuscoinMngr: wallet name is empty")
+	}
+	w, err := rm.GetWallet(addr)
+	if err != nil {
+		return err
+	}
+	w.Name = name
+	return nil
+}
+
+func (rm *RuscoinMngr) SetWalletOffline(addr string, offline bool) error {
+	w, err := rm.GetWallet(addr)
+	if err != nil {
+		return err
+	}
+	w.Offline = offline
+	return nil
+}
+
+// Sets offset of the node clock from the common time. Limited by ten drift windows
+func (rm *RuscoinMngr) SetClockSkew(id string, skew time.Duration) error {
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return err
+	}
+	if limit := 10 * ruscoin.MAX_FUTURE_DRIFT; skew < -limit || skew > limit {
+		return fmt.Errorf("RuscoinMngr: clock skew must be in range -%s..%s", limit, limit)
+	}
+	n.ClockSkew = skew
+	return nil
+}
+
+// Creates transaction from wallet to address using wallet coin selection
+// and sends it to the network
+func (rm *RuscoinMngr) SendCoins(from *ruscoin.Wallet, to string, amount int, l EmuLogger) (*ruscoin.Transaction, error) {
+	return rm.SendPayments(from, []ruscoin.Payment{{Addr: to, Amount: amount}}, l)
+}
+
+// Creates transaction paying several recipients at once and sends it to the network.
+// Payment to a wallet goes to its next receiving address. Coins spent by wallet transactions
+// waiting in the entry node mempool are skipped, their change may be spent
+func (rm *RuscoinMngr) SendPayments(from *ruscoin.Wallet, pays []ruscoin.Payment, l EmuLogger) (*ruscoin.Transaction, error) {
+	pays = slices.Clone(pays)
+	for i, p := range pays {
+		if w, ok := rm.Wallets[p.Addr]; ok && w != from {
+			pays[i].Addr = w.ReceiveAddr()
+		}
+	}
+	t, err := from.SendManyFrom(rm.SpendableUtxo(from, rm.EntryNode(from)), pays)
+	if err != nil {
+		return nil, err
+	}
+	if err = rm.SubmitTransaction(from, t, l); err != nil {
 		return nil, err
 	}
-	rm.UpdateWalletsUtxo(b)
-	return b, nil
+	return t, nil
+}
+
+// Mines block candidate of the main node. Block rejected by the miner itself is returned
+// with the error for the report
+func (rm *RuscoinMngr) Mine() (*ruscoin.Block, error) {
+	return rm.GetSetMainNode().Mine()
 }
 
 func (rm *RuscoinMngr) EvryNode(f func(n *ruscoin.Node) error) error {
-	for _, n := range rm.Nodes {
+	for _, id := range rm.NodeIds() {
+		if err := f(rm.Nodes[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rm *RuscoinMngr) EvryOnlineNode(f func(n *ruscoin.Node) error) error {
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		if n.Offline {
+			continue
+		}
 		if err := f(n); err != nil {
 			return err
 		}
@@ -115,11 +492,11 @@ func (rm *RuscoinMngr) EvryNonMainNode(f func(n *ruscoin.Node) error) error {
 	if rm.mainNode == nil {
 		return fmt.Errorf("Emulator Server: main node not set")
 	}
-	for k, n := range rm.Nodes {
+	for _, k := range rm.NodeIds() {
 		if k == rm.mainNode.Id {
 			continue
 		}
-		if err := f(n); err != nil {
+		if err := f(rm.Nodes[k]); err != nil {
 			return err
 		}
 	}
@@ -142,25 +519,115 @@ func (rm *RuscoinMngr) GetNodeBlock(nodeId string, height int) (*ruscoin.Block,
 	return n.BlockChain[height], nil
 }
 
-func (rm *RuscoinMngr) UpdateWalletsUtxo(b *ruscoin.Block) {
-	for _, t := range b.Body.Transactions {
-		for id, u := range t.InputUtxo {
-			if w, ok := rm.Wallets[u.Addr]; ok {
-				w.RemoveUtxo(id)
-			}
+// Node the wallet syncs from: attached node or, if wallet is not attached or the node is
+// gone, the public node
+func (rm *RuscoinMngr) WalletNode(w *ruscoin.Wallet) *ruscoin.Node {
+	if n, ok := rm.Nodes[w.NodeId]; ok {
+		return n
+	}
+	return rm.PublicNode()
+}
+
+// Scans new blocks of wallet nodes. Wallet whose node switched to other fork rescans it
+func (rm *RuscoinMngr) SyncWallets(l EmuLogger) {
+	for _, addr := range slices.Sorted(maps.Keys(rm.Wallets)) {
+		w := rm.Wallets[addr]
+		n := rm.WalletNode(w)
+		if n == nil {
+			continue
 		}
-		for id, u := range t.OutputUtxo {
-			if w, ok := rm.Wallets[u.Addr]; ok {
-				w.AddUtxo(id, u.Addr, u.Amount)
-			}
+		if undone := w.Sync(n); undone > 0 {
+			l.Evil("Wallet [%s]: node [%s] switched fork, %d blocks undone, wallet rescanned", w.Name, n.Name, undone)
 		}
 	}
 }
 
+// Rebuilds wallet utxo from the whole chain of its node
+func (rm *RuscoinMngr) RescanWallet(w *ruscoin.Wallet) error {
+	n := rm.WalletNode(w)
+	if n == nil {
+		return fmt.Errorf("RuscoinMngr: no node to rescan wallet [%s] from", w.Name)
+	}
+	w.Rescan(n)
+	return nil
+}
+
+// Attaches wallet to node, nil node makes wallet follow the public chain. Wallet rescans
+// the chain of its new node
+func (rm *RuscoinMngr) AttachWallet(w *ruscoin.Wallet, n *ruscoin.Node) {
+	w.NodeId = ""
+	if n != nil {
+		w.NodeId = n.Id
+	}
+	if wn := rm.WalletNode(w); wn != nil {
+		w.Rescan(wn)
+	}
+}
+
+// Online honest node with the longest chain. Private chain of an attacker is not public
+func (rm *RuscoinMngr) PublicNode() *ruscoin.Node {
+	switch {
+	case rm.Selfish != nil:
+		return rm.BestPeer(rm.Selfish.Node.Id)
+	case rm.DoubleSpend.Running():
+		return rm.BestPeer(rm.DoubleSpend.Node.Id)
+	}
+	// blocks of withholding miners are private too
+	var best *ruscoin.Node
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		if n.Offline || rm.Behaviour(n).Kind() == BEHAVIOUR_WITHHOLD {
+			continue
+		}
+		if best == nil || len(n.BlockChain) > len(best.BlockChain) {
+			best = n
+		}
+	}
+	if best == nil {
+		return rm.BestPeer("")
+	}
+	return best
+}
+
+// Length of the public chain
+func (rm *RuscoinMngr) publicLen() int {
+	if n := rm.PublicNode(); n != nil {
+		return len(n.BlockChain)
+	}
+	return 0
+}
+
+// Sets hash power of the node
+func (rm *RuscoinMngr) SetHashPower(id string, power int) error {
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return err
+	}
+	if power < 0 || power > HASH_POWER_MAX {
+		return fmt.Errorf("RuscoinMngr: hash power must be in range 0..%d", HASH_POWER_MAX)
+	}
+	n.HashPower = power
+	return nil
+}
+
+func (rm *RuscoinMngr) recordRejected(n *ruscoin.Node, b *ruscoin.Block, err error) {
+	rm.Rejected = append(rm.Rejected, RejectedBlock{
+		Tick:   rm.Tick,
+		Node:   n.Name,
+		Height: b.Header.Height,
+		Hash:   hex.EncodeToString(b.Header.Hash),
+		Reason: err.Error(),
+	})
+}
+
 func (rm *RuscoinMngr) consensusCheck(b *ruscoin.Block) (bool, []error) {
 	nl := len(rm.Nodes)
 	errs := make([]error, 0, nl)
-	for _, n := range rm.Nodes {
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		if n.Offline {
+			continue
+		}
 		if err := n.VerifyBlock(b); err != nil {
 			errs = append(errs, err)
 		}
@@ -0,0 +1,1147 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"myruscoint/internal/ruscoin"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scenario step actions
+const (
+	SC_TICK          = "tick"
+	SC_MINER         = "miner"
+	SC_TX            = "tx"
+	SC_BUMP          = "bump"
+	SC_WALLET_NODE   = "wallet_node"
+	SC_RECOVER       = "recover"
+	SC_MS_CREATE     = "ms_create"
+	SC_MS_SPEND      = "ms_spend"
+	SC_MS_SIGN       = "ms_sign"
+	SC_MS_SUBMIT     = "ms_submit"
+	SC_CRASH         = "crash"
+	SC_RESTORE       = "restore"
+	SC_EVIL_STEAL    = "evil_steal"
+	SC_EVIL_SET      = "evil_set"
+	SC_EVIL_COINS    = "evil_coins"
+	SC_EVIL_MINE     = "evil_mine"
+	SC_EVIL_INJECT   = "evil_inject"
+	SC_EVIL_SEND     = "evil_send"
+	SC_EVIL_FIX      = "evil_fix"
+	SC_EVIL_RAW      = "evil_raw"
+	SC_EVIL_REPLAY   = "evil_replay"
+	SC_HASH_POWER    = "hash_power"
+	SC_SELFISH_START = "selfish_start"
+	SC_SELFISH_STOP  = "selfish_stop"
+	SC_DOUBLE_SPEND  = "double_spend"
+	SC_LINK          = "link"
+	SC_UNLINK        = "unlink"
+	SC_CUT           = "cut"
+	SC_SPLIT         = "split"
+	SC_ECLIPSE       = "eclipse"
+	SC_HEAL          = "heal"
+	SC_SYBIL_START   = "sybil_start"
+	SC_SYBIL_STOP    = "sybil_stop"
+	SC_BEHAVIOUR     = "behaviour"
+	SC_CLOCK         = "clock"
+	SC_EXPECT        = "expect"
+)
+
+// Expected outcome of double-spend attack
+const (
+	DS_RUNNING   = "running"
+	DS_REVERSED  = "reversed"
+	DS_CONFIRMED = "confirmed"
+)
+
+// Declarative description of emulation: participants, topology, initial balances,
+// timeline of actions and expected outcomes
+type Scenario struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Settings    ScenarioSettings `json:"settings"`
+	Nodes       []string         `json:"nodes"`
+	Wallets     []ScenarioWallet `json:"wallets"`
+	Links       [][2]string      `json:"links"`
+	Steps       []ScenarioStep   `json:"steps"`
+}
+
+// Overrides of ruscoin settings. Zero values keep current settings
+type ScenarioSettings struct {
+	Diff     string `json:"diff"`
+	Reward   int    `json:"reward"`
+	Coinbase int    `json:"coinbase"`
+	// Seed of deterministic mode. If not set RUSCOIN_SEED is used
+	Seed int64 `json:"seed"`
+	// Chain id signed by transactions and replay protection, if not set session value is kept
+	ChainId          string `json:"chain_id"`
+	ReplayProtection *bool  `json:"replay_protection"`
+	// Block size limit in bytes
+	MaxBlockSize int `json:"max_block_size"`
+}
+
+type ScenarioWallet struct {
+	Name string `json:"name"`
+	// Coins allocated to the wallet in genesis block
+	Balance int `json:"balance"`
+	// Node the wallet syncs from, the public chain if empty
+	Node string `json:"node,omitempty"`
+	// HD wallet with keys from seed phrase, random phrase if mnemonic is empty
+	HD       bool   `json:"hd,omitempty"`
+	Mnemonic string `json:"mnemonic,omitempty"`
+}
+
+type ScenarioStep struct {
+	Action  string `json:"action"`
+	Comment string `json:"comment,omitempty"`
+	// tick: number of ticks, 1 if not set. double_spend: confirmations victim waits.
+	// cut, split, eclipse: ticks until heal, 0 - until heal step. sybil_start: number of Sybil nodes.
+	// evil_fix: evil block transaction index. evil_replay: block height
+	Count int `json:"count,omitempty"`
+	// miner, crash, restore: node name
+	Node string `json:"node,omitempty"`
+	// tx, evil_coins: wallet names and amount. hash_power: amount is node hash power.
+	// double_spend: node pays amount to wallet To. link, unlink, cut: node names.
+	// sybil_start: amount is links per victim, To is optional target wallet. clock: amount is node clock skew in seconds.
+	// evil_fix: From is wallet which signs transaction. evil_replay: node chain, amount is transaction index.
+	// ms_create: To is account name, amount is required signs. ms_spend: From account pays amount to wallet To.
+	// ms_sign: From wallet signs spend of To account. ms_submit: From account
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Amount int    `json:"amount,omitempty"`
+	// split: groups of node names. eclipse: attacker node names. sybil_start: victim node names
+	Groups [][]string `json:"groups,omitempty"`
+	Nodes  []string   `json:"nodes,omitempty"`
+	// ms_create: wallet names of multisig cosigners
+	Wallets []string `json:"wallets,omitempty"`
+	// evil_set: field name and value. evil_fix: field is fix. evil_raw: field name of serialised block and hex value.
+	// evil_replay: value is serialised transaction in hex instead of node chain transaction.
+	// selfish_start: value is gamma. sybil_start: value is mode.
+	// behaviour: value is kind, field is kind parameter. ms_spend: value is fee.
+	// ms_submit: field evil puts the spend into the evil block
+	Field string `json:"field,omitempty"`
+	Value string `json:"value,omitempty"`
+	// sybil_start: delay of delay mode in ticks and whether victims lose honest links
+	Delay     int  `json:"delay,omitempty"`
+	Exclusive bool `json:"exclusive,omitempty"`
+	// expect: expected emulation state
+	Expect *ScenarioExpect `json:"expect,omitempty"`
+}
+
+// Expected emulation state. Only set fields are checked
+type ScenarioExpect struct {
+	// Node name to last block height
+	Height map[string]int `json:"height,omitempty"`
+	// Wallet name to balance
+	Balance  map[string]int `json:"balance,omitempty"`
+	Rejected *int           `json:"rejected,omitempty"`
+	Forks    *int           `json:"forks,omitempty"`
+	Miner    string         `json:"miner,omitempty"`
+	// All online nodes have the same last block
+	SameTip bool `json:"same_tip,omitempty"`
+	// Minimal revenue share of selfish miner in percents
+	SelfishShareMin *float64 `json:"selfish_share_min,omitempty"`
+	// Double-spend attack state: running, reversed or confirmed
+	DoubleSpend string `json:"double_spend,omitempty"`
+	// Node name to number of mempool transactions
+	Mempool map[string]int `json:"mempool,omitempty"`
+	// Wallet name to number of its utxos
+	Utxo map[string]int `json:"utxo,omitempty"`
+	// Wallet name to ticks its last transaction waited for the public chain, -1 - still pending
+	Waited map[string]int `json:"waited,omitempty"`
+	// Wallet name to spendable, immature and pending incoming balance seen by the public node
+	Available map[string]int `json:"available,omitempty"`
+	Immature  map[string]int `json:"immature,omitempty"`
+	Pending   map[string]int `json:"pending,omitempty"`
+	// Wallet name to number of its transactions in the chain of its node
+	History map[string]int `json:"history,omitempty"`
+	// Wallet name to number of its addresses which received coins
+	Addresses map[string]int `json:"addresses,omitempty"`
+}
+
+type StepResult struct {
+	Index  int
+	Action string
+	OK     bool
+	Msg    string
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Scenario: %s", err)
+	}
+	return ParseScenario(data)
+}
+
+func ParseScenario(data []byte) (*Scenario, error) {
+	sc := &Scenario{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(sc); err != nil {
+		return nil, fmt.Errorf("Scenario: failed to parse: %s", err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (sc *Scenario) Validate() error {
+	if len(sc.Nodes) == 0 {
+		return fmt.Errorf("Scenario: no nodes")
+	}
+	names := map[string]bool{}
+	for _, n := range sc.Nodes {
+		if n == "" || names[n] {
+			return fmt.Errorf("Scenario: node name [%s] is empty or not unique", n)
+		}
+		names[n] = true
+	}
+	for _, w := range sc.Wallets {
+		if w.Name == "" || names[w.Name] {
+			return fmt.Errorf("Scenario: wallet name [%s] is empty or not unique (node wallets have node names)", w.Name)
+		}
+		if w.Balance < 0 {
+			return fmt.Errorf("Scenario: wallet [%s] balance is negative", w.Name)
+		}
+		if w.Node != "" && !slices.Contains(sc.Nodes, w.Node) {
+			return fmt.Errorf("Scenario: wallet [%s]: unknown node %s", w.Name, w.Node)
+		}
+		names[w.Name] = true
+	}
+	for _, l := range sc.Links {
+		if !slices.Contains(sc.Nodes, l[0]) || !slices.Contains(sc.Nodes, l[1]) {
+			return fmt.Errorf("Scenario: link %s - %s: unknown node", l[0], l[1])
+		}
+	}
+	if sc.Settings.Diff != "" {
+		if _, ok := new(big.Int).SetString(sc.Settings.Diff, 10); !ok {
+			return fmt.Errorf("Scenario: diff is not integer")
+		}
+	}
+	for i, st := range sc.Steps {
+		if err := st.validate(); err != nil {
+			return fmt.Errorf("Scenario: step %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Step fields used by each action. Setting any other field is an error, so a field
+// put on the wrong action does not run silently as zero value
+var stepFields = map[string][]string{
+	SC_TICK:          {"count"},
+	SC_MINER:         {"node"},
+	SC_CRASH:         {"node"},
+	SC_RESTORE:       {"node"},
+	SC_TX:            {"from", "to", "amount", "field", "value"},
+	SC_BUMP:          {"from", "field", "value"},
+	SC_WALLET_NODE:   {"from", "node"},
+	SC_RECOVER:       {"from"},
+	SC_MS_CREATE:     {"to", "amount", "wallets"},
+	SC_MS_SPEND:      {"from", "to", "amount", "value"},
+	SC_MS_SIGN:       {"from", "to"},
+	SC_MS_SUBMIT:     {"from", "field"},
+	SC_EVIL_STEAL:    {},
+	SC_EVIL_MINE:     {},
+	SC_EVIL_INJECT:   {},
+	SC_EVIL_SEND:     {},
+	SC_EVIL_SET:      {"field", "value"},
+	SC_EVIL_COINS:    {"to", "amount"},
+	SC_EVIL_FIX:      {"count", "field", "from"},
+	SC_EVIL_REPLAY:   {"node", "count", "amount", "value"},
+	SC_EVIL_RAW:      {"field", "value"},
+	SC_HASH_POWER:    {"node", "amount"},
+	SC_SELFISH_START: {"node", "value"},
+	SC_SELFISH_STOP:  {},
+	SC_DOUBLE_SPEND:  {"node", "to", "amount", "count"},
+	SC_LINK:          {"from", "to"},
+	SC_UNLINK:        {"from", "to"},
+	SC_CUT:           {"from", "to", "count"},
+	SC_SPLIT:         {"groups", "count"},
+	SC_ECLIPSE:       {"node", "nodes", "count"},
+	SC_HEAL:          {},
+	SC_SYBIL_START:   {"count", "amount", "to", "nodes", "value", "delay", "exclusive"},
+	SC_SYBIL_STOP:    {},
+	SC_CLOCK:         {"node", "amount"},
+	SC_BEHAVIOUR:     {"node", "field", "value"},
+	SC_EXPECT:        {"expect"},
+}
+
+// Names of step fields which are set
+func (st ScenarioStep) setFields() []string {
+	set := []string{}
+	add := func(name string, ok bool) {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	add("count", st.Count != 0)
+	add("node", st.Node != "")
+	add("from", st.From != "")
+	add("to", st.To != "")
+	add("amount", st.Amount != 0)
+	add("groups", st.Groups != nil)
+	add("nodes", st.Nodes != nil)
+	add("wallets", st.Wallets != nil)
+	add("field", st.Field != "")
+	add("value", st.Value != "")
+	add("delay", st.Delay != 0)
+	add("exclusive", st.Exclusive)
+	add("expect", st.Expect != nil)
+	return set
+}
+
+func (st ScenarioStep) validate() error {
+	if fields, ok := stepFields[st.Action]; ok {
+		for _, f := range st.setFields() {
+			if !slices.Contains(fields, f) {
+				return fmt.Errorf("%s: field %s is not used", st.Action, f)
+			}
+		}
+	}
+	switch st.Action {
+	case SC_TICK, SC_EVIL_STEAL, SC_EVIL_MINE, SC_EVIL_INJECT, SC_EVIL_SEND, SC_SELFISH_STOP, SC_HEAL, SC_SYBIL_STOP:
+	case SC_MINER, SC_CRASH, SC_RESTORE, SC_SELFISH_START, SC_CLOCK:
+		if st.Node == "" {
+			return fmt.Errorf("%s: node not set", st.Action)
+		}
+		if _, err := strconv.ParseFloat(st.Value, 64); st.Action == SC_SELFISH_START && st.Value != "" && err != nil {
+			return fmt.Errorf("selfish_start: gamma is not a number")
+		}
+	case SC_TX:
+		if st.From == "" || st.To == "" || st.Amount < 1 {
+			return fmt.Errorf("tx: from, to and positive amount required")
+		}
+		if st.Field != "" && !slices.Contains(ruscoin.CoinSelections, st.Field) {
+			return fmt.Errorf("tx: coin selection must be one of %s", strings.Join(ruscoin.CoinSelections, ", "))
+		}
+		if fee, err := strconv.Atoi(st.Value); st.Value != "" && (err != nil || fee < 0) {
+			return fmt.Errorf("tx: fee must be non negative integer")
+		}
+	case SC_BUMP:
+		if st.From == "" || !slices.Contains(BumpModes, st.Field) {
+			return fmt.Errorf("bump: from and mode %s required", strings.Join(BumpModes, " or "))
+		}
+		if fee, err := strconv.Atoi(st.Value); err != nil || fee < 1 {
+			return fmt.Errorf("bump: fee must be positive integer")
+		}
+	case SC_WALLET_NODE:
+		if st.From == "" {
+			return fmt.Errorf("wallet_node: wallet required")
+		}
+	case SC_RECOVER:
+		if st.From == "" {
+			return fmt.Errorf("recover: wallet required")
+		}
+	case SC_MS_CREATE:
+		if st.To == "" || st.Amount < 1 || len(st.Wallets) < st.Amount {
+			return fmt.Errorf("ms_create: to, amount and at least amount wallets required")
+		}
+	case SC_MS_SPEND:
+		if st.From == "" || st.To == "" || st.Amount < 1 {
+			return fmt.Errorf("ms_spend: from, to and positive amount required")
+		}
+		if fee, err := strconv.Atoi(st.Value); st.Value != "" && (err != nil || fee < 0) {
+			return fmt.Errorf("ms_spend: fee must be non negative integer")
+		}
+	case SC_MS_SIGN:
+		if st.From == "" || st.To == "" {
+			return fmt.Errorf("ms_sign: wallet from and multisig to required")
+		}
+	case SC_MS_SUBMIT:
+		if st.From == "" || st.Field != "" && st.Field != "evil" {
+			return fmt.Errorf("ms_submit: multisig from required, field may be evil")
+		}
+	case SC_DOUBLE_SPEND:
+		if st.Node == "" || st.To == "" || st.Amount < 1 || st.Count < 1 {
+			return fmt.Errorf("double_spend: node, to, positive amount and count required")
+		}
+	case SC_LINK, SC_UNLINK, SC_CUT:
+		if st.From == "" || st.To == "" || st.Count < 0 {
+			return fmt.Errorf("%s: from and to nodes required", st.Action)
+		}
+	case SC_SPLIT:
+		if len(st.Groups) == 0 || st.Count < 0 {
+			return fmt.Errorf("split: groups required")
+		}
+	case SC_ECLIPSE:
+		if st.Node == "" || len(st.Nodes) == 0 || st.Count < 0 {
+			return fmt.Errorf("eclipse: node and attacker nodes required")
+		}
+	case SC_BEHAVIOUR:
+		if st.Node == "" || !slices.Contains(BehaviourKinds, st.Value) {
+			return fmt.Errorf("behaviour: node and kind (%s) required", strings.Join(BehaviourKinds, ", "))
+		}
+	case SC_SYBIL_START:
+		if st.Count < 1 || st.Amount < 1 || !slices.Contains(SybilModes, st.Value) {
+			return fmt.Errorf("sybil_start: count, amount and mode (%s) required", strings.Join(SybilModes, ", "))
+		}
+	case SC_EVIL_COINS:
+		if st.To == "" || st.Amount < 1 {
+			return fmt.Errorf("evil_coins: to and positive amount required")
+		}
+	case SC_HASH_POWER:
+		if st.Node == "" || st.Amount < 0 {
+			return fmt.Errorf("hash_power: node and not negative amount required")
+		}
+	case SC_EVIL_SET:
+		if !slices.Contains(EvilFields, st.Field) {
+			return fmt.Errorf("evil_set: unknown field %s", st.Field)
+		}
+	case SC_EVIL_FIX:
+		if !slices.Contains(EvilFixes, st.Field) || st.Count < 0 {
+			return fmt.Errorf("evil_fix: field (%s) required", strings.Join(EvilFixes, ", "))
+		}
+		if st.Field == EVIL_FIX_SIGN && st.From == "" {
+			return fmt.Errorf("evil_fix: sign needs from wallet")
+		}
+	case SC_EVIL_REPLAY:
+		if st.Value == "" && (st.Node == "" || st.Amount < 1 || st.Count < 0) {
+			return fmt.Errorf("evil_replay: node, block count and transaction amount or hex value required")
+		}
+	case SC_EVIL_RAW:
+		if st.Field == "" {
+			return fmt.Errorf("evil_raw: field required")
+		}
+	case SC_EXPECT:
+		if st.Expect == nil {
+			return fmt.Errorf("expect: expectations not set")
+		}
+		if d := st.Expect.DoubleSpend; d != "" && !slices.Contains([]string{DS_RUNNING, DS_REVERSED, DS_CONFIRMED}, d) {
+			return fmt.Errorf("expect: unknown double_spend state %s", d)
+		}
+	default:
+		return fmt.Errorf("unknown action %s", st.Action)
+	}
+	return nil
+}
+
+// Applies scenario settings to ruscoin package settings. Settings which are not set keep
+// their session values
+func (sc *Scenario) ApplySettings() {
+	if sc.Settings.Diff != "" {
+		ruscoin.MINE_DIFF = sc.Settings.Diff
+	}
+	if sc.Settings.Reward > 0 {
+		ruscoin.REWARD_AMOUNT = sc.Settings.Reward
+	}
+	if sc.Settings.Coinbase > 0 {
+		ruscoin.COINBASE_START_AMOUNT = sc.Settings.Coinbase
+	}
+	if sc.Settings.ChainId != "" {
+		ruscoin.CHAIN_ID = sc.Settings.ChainId
+	}
+	if sc.Settings.ReplayProtection != nil {
+		ruscoin.REPLAY_PROTECTION = *sc.Settings.ReplayProtection
+	}
+	if sc.Settings.MaxBlockSize > 0 {
+		ruscoin.MAX_BLOCK_SIZE = sc.Settings.MaxBlockSize
+	}
+}
+
+// Runs scenario steps one by one on it's own emulation
+type ScenarioRunner struct {
+	Sc      *Scenario
+	Rm      *RuscoinMngr
+	Results []StepResult
+	pos     int
+	// Session settings restored by Close
+	saved *ruscoin.Settings
+}
+
+// Applies scenario settings and creates emulation with scenario nodes, wallets and links.
+// Settings stay in effect until Close
+func NewScenarioRunner(sc *Scenario) (*ScenarioRunner, error) {
+	saved := ruscoin.CurrentSettings()
+	sc.ApplySettings()
+	rm, err := sc.newEmulation()
+	if err != nil {
+		saved.Apply()
+		return nil, err
+	}
+	return &ScenarioRunner{Sc: sc, Rm: rm, saved: &saved}, nil
+}
+
+// Restores session settings, random and time sources replaced by the scenario
+func (r *ScenarioRunner) Close() {
+	if r.saved != nil {
+		r.saved.Apply()
+		r.saved = nil
+	}
+}
+
+func (sc *Scenario) newEmulation() (*RuscoinMngr, error) {
+	seed := sc.Settings.Seed
+	if seed == 0 {
+		seed = ruscoin.SEED
+	}
+	rm := NewRuscoinMngr().WithSeed(seed)
+	rm.GenesisAlloc = make(map[string]int)
+	for _, name := range sc.Nodes {
+		if _, err := rm.NewNode(name); err != nil {
+			return nil, err
+		}
+	}
+	for _, sw := range sc.Wallets {
+		var w *ruscoin.Wallet
+		var err error
+		if sw.HD || sw.Mnemonic != "" {
+			w, err = rm.NewHDWallet(sw.Name, sw.Mnemonic)
+		} else {
+			w, err = rm.NewWallet(sw.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sw.Balance > 0 {
+			rm.GenesisAlloc[w.Addr] = sw.Balance
+		}
+		if n, err := rm.NodeByName(sw.Node); err == nil {
+			w.NodeId = n.Id
+		}
+	}
+	for _, l := range sc.Links {
+		a, _ := rm.NodeByName(l[0])
+		b, _ := rm.NodeByName(l[1])
+		rm.Link(a.Id, b.Id)
+	}
+	return rm, nil
+}
+
+func (r *ScenarioRunner) Done() bool {
+	return r.pos >= len(r.Sc.Steps)
+}
+
+// Index of the next step
+func (r *ScenarioRunner) Pos() int {
+	return r.pos
+}
+
+// Executes next scenario step
+func (r *ScenarioRunner) Step(l EmuLogger) StepResult {
+	if r.Done() {
+		return StepResult{Index: r.pos, OK: false, Msg: "scenario finished"}
+	}
+	st := r.Sc.Steps[r.pos]
+	res := StepResult{Index: r.pos, Action: st.Action, OK: true}
+	if st.Comment != "" {
+		l.Info("Scenario step %d: %s", r.pos+1, st.Comment)
+	}
+	msg, err := r.exec(st, l)
+	if err != nil {
+		res.OK = false
+		res.Msg = err.Error()
+		l.Error("Scenario step %d (%s): %s", r.pos+1, st.Action, err)
+	} else {
+		res.Msg = msg
+		l.OK("Scenario step %d (%s): %s", r.pos+1, st.Action, msg)
+	}
+	r.Results = append(r.Results, res)
+	r.pos++
+	return res
+}
+
+// Executes all remaining steps. Returns number of failed steps
+func (r *ScenarioRunner) RunAll(l EmuLogger) int {
+	failed := 0
+	for !r.Done() {
+		if !r.Step(l).OK {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (r *ScenarioRunner) exec(st ScenarioStep, l EmuLogger) (string, error) {
+	rm := r.Rm
+	switch st.Action {
+	case SC_TICK:
+		c := max(st.Count, 1)
+		for i := 0; i < c; i++ {
+			if err := rm.RunTick(l); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("tick %d", rm.Tick), nil
+	case SC_MINER:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		if _, err = rm.SetMainNode(n.Id); err != nil {
+			return "", err
+		}
+		l.MinerUpdate()
+		return "miner set to " + n.Name, nil
+	case SC_TX:
+		from, err := rm.WalletByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		pays := []ruscoin.Payment{}
+		for _, name := range strings.Split(st.To, ",") {
+			addr, err := rm.scenarioAddr(strings.TrimSpace(name))
+			if err != nil {
+				return "", err
+			}
+			pays = append(pays, ruscoin.Payment{Addr: addr, Amount: st.Amount})
+		}
+		if st.Field != "" {
+			from.CoinSelection = st.Field
+		}
+		if st.Value != "" {
+			from.Fee, _ = strconv.Atoi(st.Value)
+		}
+		t, err := rm.SendPayments(from, pays, l)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s sends %d to %s: %d inputs, %d outputs", from.Name, st.Amount, st.To, len(t.InputUtxo), len(t.OutputUtxo)), nil
+	case SC_BUMP:
+		w, err := rm.WalletByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		fee, _ := strconv.Atoi(st.Value)
+		if _, err := rm.BumpFee(w, st.Field, fee, l); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s bumps fee to %d by %s", w.Name, fee, st.Field), nil
+	case SC_WALLET_NODE:
+		w, err := rm.WalletByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		if st.Node == "" {
+			rm.AttachWallet(w, nil)
+			return w.Name + " follows the public chain", nil
+		}
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		rm.AttachWallet(w, n)
+		return w.Name + " attached to " + n.Name, nil
+	case SC_RECOVER:
+		w, err := rm.WalletByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		if w, err = rm.RecoverWallet(w); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s recovered from seed phrase: balance %d, %d addresses", w.Name, w.Balance(), len(w.Addrs())), nil
+	case SC_MS_CREATE:
+		owners := []*ruscoin.Wallet{}
+		for _, name := range st.Wallets {
+			w, err := rm.WalletByName(name)
+			if err != nil {
+				return "", err
+			}
+			owners = append(owners, w)
+		}
+		a, err := rm.NewMultisig(st.To, st.Amount, owners)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("multisig %s %s of %s", a.Name, a.Ms, strings.Join(a.Owners, ", ")), nil
+	case SC_MS_SPEND:
+		a, err := rm.MultisigByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		to, err := rm.scenarioAddr(st.To)
+		if err != nil {
+			return "", err
+		}
+		fee, _ := strconv.Atoi(st.Value)
+		t, err := rm.ProposeMultisigSpend(a, to, st.Amount, fee)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s proposes to pay %d to %s: %d inputs, %d outputs", a.Name, st.Amount, st.To, len(t.InputUtxo), len(t.OutputUtxo)), nil
+	case SC_MS_SIGN:
+		w, err := rm.WalletByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		a, err := rm.MultisigByName(st.To)
+		if err != nil {
+			return "", err
+		}
+		signed := len(a.Signed) + 1
+		sent, err := rm.SignMultisig(a, w, l)
+		if err != nil {
+			return "", err
+		}
+		if sent {
+			return fmt.Sprintf("%s signs %s spend, %d of %d signs, spend sent", w.Name, a.Name, signed, a.Ms.M), nil
+		}
+		return fmt.Sprintf("%s signs %s spend, %d of %d signs", w.Name, a.Name, signed, a.Ms.M), nil
+	case SC_MS_SUBMIT:
+		a, err := rm.MultisigByName(st.From)
+		if err != nil {
+			return "", err
+		}
+		if st.Field == "evil" {
+			i, err := rm.EvilAddMultisig(a)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s spend with %d signs is transaction %d of evil block", a.Name, len(a.Signed), i), nil
+		}
+		if err := rm.SubmitMultisig(a, l); err != nil {
+			return "", err
+		}
+		return a.Name + " spend sent", nil
+	case SC_CRASH:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		if err = rm.CrashNode(n.Id); err != nil {
+			return "", err
+		}
+		l.MinerUpdate()
+		return "node " + n.Name + " crashed", nil
+	case SC_RESTORE:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		added, err := rm.RestoreNode(n.Id)
+		if err != nil {
+			return "", err
+		}
+		if rm.MainNode() == nil {
+			rm.SelectMainNode()
+			l.MinerUpdate()
+		}
+		return fmt.Sprintf("node %s restored, synced %d blocks", n.Name, added), nil
+	case SC_EVIL_STEAL:
+		if _, err := rm.EvilSteal(); err != nil {
+			return "", err
+		}
+		return "block candidate stolen", nil
+	case SC_EVIL_SET:
+		if err := rm.EvilSetField(st.Field, st.Value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s set to %s", st.Field, st.Value), nil
+	case SC_EVIL_COINS:
+		w, err := rm.WalletByName(st.To)
+		if err != nil {
+			return "", err
+		}
+		if _, err = rm.EvilAddCoins(w.Addr, st.Amount); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d coins for %s added", st.Amount, w.Name), nil
+	case SC_EVIL_MINE:
+		if _, err := rm.EvilMine(); err != nil {
+			return "", err
+		}
+		return "evil block mined by " + rm.MainNode().Name, nil
+	case SC_EVIL_INJECT:
+		if err := rm.EvilInject(); err != nil {
+			return "", err
+		}
+		return "evil block injected into " + rm.MainNode().Name, nil
+	case SC_EVIL_SEND:
+		accepted, err := rm.EvilSend(l)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("evil block accepted by %d nodes", accepted), nil
+	case SC_EVIL_FIX:
+		addr := ""
+		if st.From != "" {
+			w, err := rm.WalletByName(st.From)
+			if err != nil {
+				return "", err
+			}
+			addr = w.Addr
+		}
+		if err := rm.EvilFix(st.Field, st.Count, addr, l); err != nil {
+			return "", err
+		}
+		return "evil block " + st.Field + " fixed", nil
+	case SC_EVIL_REPLAY:
+		var i int
+		var err error
+		if st.Value != "" {
+			i, err = rm.EvilImportTx(st.Value, l)
+		} else {
+			n, nerr := rm.NodeByName(st.Node)
+			if nerr != nil {
+				return "", nerr
+			}
+			i, err = rm.EvilReplay(n.Id, st.Count, st.Amount, l)
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("transaction replayed as evil transaction %d", i), nil
+	case SC_EVIL_RAW:
+		if err := rm.EvilRawPatch(st.Field, st.Value, l); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("raw %s set to %s", st.Field, st.Value), nil
+	case SC_HASH_POWER:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		if err = rm.SetHashPower(n.Id, st.Amount); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("node %s hash power %d", n.Name, st.Amount), nil
+	case SC_SELFISH_START:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		gamma := 0.0
+		if st.Value != "" {
+			if gamma, err = strconv.ParseFloat(st.Value, 64); err != nil {
+				return "", fmt.Errorf("gamma is not a number")
+			}
+		}
+		if _, err = rm.StartSelfishMining(n.Id, gamma); err != nil {
+			return "", err
+		}
+		return "selfish mining started by " + n.Name, nil
+	case SC_SELFISH_STOP:
+		if err := rm.StopSelfishMining(l); err != nil {
+			return "", err
+		}
+		return "selfish mining stopped", nil
+	case SC_DOUBLE_SPEND:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		w, err := rm.WalletByName(st.To)
+		if err != nil {
+			return "", err
+		}
+		if _, err = rm.StartDoubleSpend(n.Id, w.Addr, st.Amount, st.Count, l); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s pays %d to %s and mines private fork", n.Name, st.Amount, w.Name), nil
+	case SC_LINK, SC_UNLINK, SC_CUT:
+		ids, err := r.nodeIds([]string{st.From, st.To})
+		if err != nil {
+			return "", err
+		}
+		switch st.Action {
+		case SC_LINK:
+			err = rm.Link(ids[0], ids[1])
+		case SC_UNLINK:
+			err = rm.Unlink(ids[0], ids[1])
+		default:
+			err = rm.CutLink(ids[0], ids[1], st.Count)
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s - %s", st.Action, st.From, st.To), nil
+	case SC_SPLIT:
+		groups := [][]string{}
+		for _, g := range st.Groups {
+			ids, err := r.nodeIds(g)
+			if err != nil {
+				return "", err
+			}
+			groups = append(groups, ids)
+		}
+		if err := rm.SplitNetwork(groups, st.Count); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("network split into %d groups", len(groups)), nil
+	case SC_ECLIPSE:
+		ids, err := r.nodeIds(append([]string{st.Node}, st.Nodes...))
+		if err != nil {
+			return "", err
+		}
+		if err = rm.StartEclipse(ids[0], ids[1:], st.Count); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("node %s eclipsed by %s", st.Node, strings.Join(st.Nodes, ", ")), nil
+	case SC_HEAL:
+		rm.HealNetwork(l)
+		return "network healed", nil
+	case SC_SYBIL_START:
+		victims, err := r.nodeIds(st.Nodes)
+		if err != nil {
+			return "", err
+		}
+		c := SybilConfig{
+			Owner:     "Sybil",
+			Count:     st.Count,
+			PerVictim: st.Amount,
+			Victims:   victims,
+			Exclusive: st.Exclusive,
+			Mode:      st.Value,
+			Delay:     st.Delay,
+		}
+		if st.To != "" {
+			w, err := rm.WalletByName(st.To)
+			if err != nil {
+				return "", err
+			}
+			c.Targets = []string{w.Addr}
+		}
+		if _, err = rm.StartSybil(c, l); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d Sybil nodes started, mode %s", st.Count, st.Value), nil
+	case SC_CLOCK:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		if err = rm.SetClockSkew(n.Id, time.Duration(st.Amount)*time.Second); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("node %s clock skew %ds", n.Name, st.Amount), nil
+	case SC_BEHAVIOUR:
+		n, err := rm.NodeByName(st.Node)
+		if err != nil {
+			return "", err
+		}
+		b, err := rm.NewBehaviour(st.Value, st.Field)
+		if err != nil {
+			return "", err
+		}
+		if err = rm.SetBehaviour(n.Id, b); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("node %s is %s", n.Name, b), nil
+	case SC_SYBIL_STOP:
+		if err := rm.StopSybil(l); err != nil {
+			return "", err
+		}
+		return "Sybil nodes removed", nil
+	case SC_EXPECT:
+		if errs := r.check(st.Expect); len(errs) > 0 {
+			return "", fmt.Errorf("expectation failed: %s", strings.Join(errs, "; "))
+		}
+		return "expectations met", nil
+	}
+	return "", fmt.Errorf("unknown action %s", st.Action)
+}
+
+// Ids of nodes with given names
+func (r *ScenarioRunner) nodeIds(names []string) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		n, err := r.Rm.NodeByName(name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, n.Id)
+	}
+	return ids, nil
+}
+
+// Returns list of failed expectations
+func (r *ScenarioRunner) check(e *ScenarioExpect) []string {
+	rm := r.Rm
+	errs := []string{}
+	s := rm.Summary()
+	for name, h := range e.Height {
+		n, err := rm.NodeByName(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if got := len(n.BlockChain) - 1; got != h {
+			errs = append(errs, fmt.Sprintf("node %s height %d, expected %d", name, got, h))
+		}
+	}
+	for name, b := range e.Balance {
+		if a, err := rm.MultisigByName(name); err == nil {
+			if got := rm.MultisigUtxo(a).Sum(); got != b {
+				errs = append(errs, fmt.Sprintf("multisig %s balance %d, expected %d", name, got, b))
+			}
+			continue
+		}
+		w, err := rm.WalletByName(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if got := w.Balance(); got != b {
+			errs = append(errs, fmt.Sprintf("wallet %s balance %d, expected %d", name, got, b))
+		}
+	}
+	if e.Rejected != nil && len(s.Rejected) != *e.Rejected {
+		errs = append(errs, fmt.Sprintf("rejected blocks %d, expected %d", len(s.Rejected), *e.Rejected))
+	}
+	if e.Forks != nil && s.Forks != *e.Forks {
+		errs = append(errs, fmt.Sprintf("forks %d, expected %d", s.Forks, *e.Forks))
+	}
+	if e.Miner != "" {
+		if m := rm.MainNode(); m == nil || m.Name != e.Miner {
+			errs = append(errs, fmt.Sprintf("miner is not %s", e.Miner))
+		}
+	}
+	if e.SelfishShareMin != nil {
+		if rm.Selfish == nil {
+			errs = append(errs, "selfish mining is not running")
+		} else if sh := rm.Selfish.Last().Share(); sh < *e.SelfishShareMin {
+			errs = append(errs, fmt.Sprintf("selfish revenue share %.1f%%, expected at least %.1f%%", sh, *e.SelfishShareMin))
+		}
+	}
+	if e.DoubleSpend != "" {
+		got := ""
+		switch ds := rm.DoubleSpend; {
+		case ds == nil:
+			got = "not started"
+		case ds.Running():
+			got = DS_RUNNING
+		case ds.Reversed:
+			got = DS_REVERSED
+		default:
+			got = DS_CONFIRMED
+		}
+		if got != e.DoubleSpend {
+			errs = append(errs, fmt.Sprintf("double spend %s, expected %s", got, e.DoubleSpend))
+		}
+	}
+	for name, c := range e.Mempool {
+		n, err := rm.NodeByName(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if got := len(n.Mempool); got != c {
+			errs = append(errs, fmt.Sprintf("node %s mempool %d, expected %d", name, got, c))
+		}
+	}
+	for name, c := range e.Utxo {
+		w, err := rm.WalletByName(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if got := len(w.Utxo); got != c {
+			errs = append(errs, fmt.Sprintf("wallet %s has %d utxo, expected %d", name, got, c))
+		}
+	}
+	for _, c := range []struct {
+		what string
+		want map[string]int
+		got  func(v *WalletView) int
+	}{
+		{"available", e.Available, (*WalletView).Available},
+		{"immature", e.Immature, func(v *WalletView) int { return v.Immature }},
+		{"pending", e.Pending, func(v *WalletView) int { return v.PendingIn }},
+	} {
+		for name, b := range c.want {
+			w, err := rm.WalletByName(name)
+			n := rm.PublicNode()
+			switch {
+			case err != nil:
+				errs = append(errs, err.Error())
+			case n == nil:
+				errs = append(errs, "no public node")
+			default:
+				if got := c.got(rm.WalletView(w, n)); got != b {
+					errs = append(errs, fmt.Sprintf("wallet %s %s balance %d, expected %d", name, c.what, got, b))
+				}
+			}
+		}
+	}
+	for name, c := range e.History {
+		w, err := rm.WalletByName(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if h, err := rm.WalletHistory(w); err != nil {
+			errs = append(errs, err.Error())
+		} else if len(h) != c {
+			errs = append(errs, fmt.Sprintf("wallet %s history has %d transactions, expected %d", name, len(h), c))
+		}
+	}
+	for name, c := range e.Addresses {
+		w, err := rm.WalletByName(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		used := 0
+		for _, a := range w.Addrs() {
+			if a.Used {
+				used++
+			}
+		}
+		if used != c {
+			errs = append(errs, fmt.Sprintf("wallet %s has %d used addresses, expected %d", name, used, c))
+		}
+	}
+	for name, c := range e.Waited {
+		w := rm.LastTxWait(name)
+		switch {
+		case w == nil:
+			errs = append(errs, fmt.Sprintf("wallet %s sent no transactions", name))
+		case c < 0 && w.Mined >= 0:
+			errs = append(errs, fmt.Sprintf("wallet %s transaction included after %d ticks, expected pending", name, w.Waited(rm.Tick)))
+		case c >= 0 && w.Mined < 0:
+			errs = append(errs, fmt.Sprintf("wallet %s transaction pending for %d ticks, expected included after %d", name, w.Waited(rm.Tick), c))
+		case c >= 0 && w.Waited(rm.Tick) != c:
+			errs = append(errs, fmt.Sprintf("wallet %s transaction waited %d ticks, expected %d", name, w.Waited(rm.Tick), c))
+		}
+	}
+	if e.SameTip {
+		tips := map[string]bool{}
+		for _, n := range rm.Nodes {
+			if b := n.GetLastBlock(); b != nil && !n.Offline {
+				tips[b.HashString()] = true
+			}
+		}
+		if len(tips) > 1 {
+			errs = append(errs, fmt.Sprintf("online nodes have %d different tips", len(tips)))
+		}
+	}
+	slices.Sort(errs)
+	return errs
+}
+
+// Returns sorted list of scenario file names (*.json) in dir
+func ScenarioFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Scenario: %s", err)
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, e.Name())
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Address of wallet or multisig account with the name
+func (rm *RuscoinMngr) scenarioAddr(name string) (string, error) {
+	if a, err := rm.MultisigByName(name); err == nil {
+		return a.Addr(), nil
+	}
+	w, err := rm.WalletByName(name)
+	if err != nil {
+		return "", err
+	}
+	return w.Addr, nil
+}
@@ -0,0 +1,136 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	SCHED_TPM_MIN = 1
+	SCHED_TPM_MAX = 600
+)
+
+// Background simulation clock. Calls tickFn with the configured speed
+// (ticks per minute) while running
+type TickScheduler struct {
+	mu      sync.Mutex
+	running bool
+	tpm     int
+	tickFn  func() error
+	onState func()
+	wake    chan struct{}
+}
+
+func NewTickScheduler(tpm int, tickFn func() error) *TickScheduler {
+	s := &TickScheduler{
+		tickFn: tickFn,
+		wake:   make(chan struct{}, 1),
+	}
+	if err := s.SetSpeed(tpm); err != nil {
+		s.tpm = SCHED_TPM_MIN
+	}
+	return s
+}
+
+// Sets function which is called every time scheduler state changes
+func (s *TickScheduler) OnStateChange(f func()) *TickScheduler {
+	s.onState = f
+	return s
+}
+
+func (s *TickScheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// Ticks per minute
+func (s *TickScheduler) Speed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tpm
+}
+
+func (s *TickScheduler) Play() {
+	s.setRunning(true)
+}
+
+func (s *TickScheduler) Pause() {
+	s.setRunning(false)
+}
+
+// Runs single tick right away. Works both in paused and running state
+func (s *TickScheduler) Step() error {
+	return s.tickFn()
+}
+
+func (s *TickScheduler) SetSpeed(tpm int) error {
+	if tpm < SCHED_TPM_MIN || tpm > SCHED_TPM_MAX {
+		return fmt.Errorf("Scheduler: speed must be in range %d..%d ticks per minute", SCHED_TPM_MIN, SCHED_TPM_MAX)
+	}
+	s.mu.Lock()
+	s.tpm = tpm
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// Scheduler loop. Blocks until ctx is done
+func (s *TickScheduler) Run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		running, period := s.running, s.period()
+		s.mu.Unlock()
+
+		if !running {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+				continue
+			}
+		}
+
+		timer := time.NewTimer(period)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		if err := s.tickFn(); err != nil {
+			s.Pause()
+		}
+	}
+}
+
+func (s *TickScheduler) setRunning(r bool) {
+	s.mu.Lock()
+	changed := s.running != r
+	s.running = r
+	s.mu.Unlock()
+	if changed {
+		s.notify()
+	}
+}
+
+// Wakes up scheduler loop and reports new state
+func (s *TickScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	if s.onState != nil {
+		s.onState()
+	}
+}
+
+func (s *TickScheduler) period() time.Duration {
+	return time.Minute / time.Duration(s.tpm)
+}
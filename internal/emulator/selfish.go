@@ -0,0 +1,204 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+)
+
+// Selfish mining attacker (Eyal and Sirer). Attacker node mines on its private chain
+// and releases withheld blocks only to orphan blocks of honest miners
+type SelfishMiner struct {
+	Node *ruscoin.Node
+	// Share of honest nodes which receive attacker block first in a tie race, 0..1
+	Gamma float64
+	// Public chain length when attack started. Revenue is counted for blocks after it
+	StartLen int
+	// Blocks of private branch mined since the last fork point
+	branchLen int
+	// Length of attacker chain known to honest nodes
+	published int
+	// Revenue share after every tick
+	History []RevenuePoint
+}
+
+type RevenuePoint struct {
+	Tick int
+	// Blocks in public chain mined by attacker and honest nodes since attack start
+	Attacker int
+	Honest   int
+	// Attacker hash power share in percents
+	HashShare float64
+}
+
+// Attacker revenue share in percents
+func (p RevenuePoint) Share() float64 {
+	if p.Attacker+p.Honest == 0 {
+		return 0
+	}
+	return 100 * float64(p.Attacker) / float64(p.Attacker+p.Honest)
+}
+
+// Makes node with given id a selfish miner
+func (rm *RuscoinMngr) StartSelfishMining(id string, gamma float64) (*SelfishMiner, error) {
+	if rm.Selfish != nil {
+		return nil, fmt.Errorf("Selfish: attack is already running by node %s", rm.Selfish.Node.Name)
+	}
+	if rm.DoubleSpend.Running() {
+		return nil, fmt.Errorf("Selfish: double-spend attack is running")
+	}
+	n, err := rm.GetNode(id)
+	if err != nil {
+		return nil, err
+	}
+	if gamma < 0 || gamma > 1 {
+		return nil, fmt.Errorf("Selfish: gamma must be in range 0..1")
+	}
+	if len(rm.Nodes) < 2 {
+		return nil, fmt.Errorf("Selfish: at least one honest node required")
+	}
+	if rm.Tick == 0 {
+		return nil, fmt.Errorf("Selfish: attack can be started after genesis block")
+	}
+	rm.Selfish = &SelfishMiner{Node: n, Gamma: gamma}
+	rm.Selfish.StartLen = rm.publicLen()
+	rm.Selfish.published = len(n.BlockChain)
+	return rm.Selfish, nil
+}
+
+// Stops the attack. Attacker publishes all withheld blocks and becomes honest
+func (rm *RuscoinMngr) StopSelfishMining(l EmuLogger) error {
+	s := rm.Selfish
+	if s == nil {
+		return fmt.Errorf("Selfish: attack is not running")
+	}
+	if len(s.Node.BlockChain) > s.published {
+		l.Evil("Selfish: attacker [%s] publishes %d withheld blocks", s.Node.Name, len(s.Node.BlockChain)-s.published)
+		rm.selfishRelease(len(s.Node.BlockChain), nil, l)
+	}
+	rm.Selfish = nil
+	rm.SyncWallets(l)
+	return nil
+}
+
+// Number of mined blocks attacker keeps private
+func (s *SelfishMiner) Withheld() int {
+	return max(len(s.Node.BlockChain)-s.published, 0)
+}
+
+// Last revenue point, zero point if there is no history yet
+func (s *SelfishMiner) Last() RevenuePoint {
+	if len(s.History) == 0 {
+		return RevenuePoint{}
+	}
+	return s.History[len(s.History)-1]
+}
+
+// Handles block just mined by miner. pubLen is public chain length before the block
+func (rm *RuscoinMngr) selfishTick(miner *ruscoin.Node, pubLen int, l EmuLogger) {
+	s := rm.Selfish
+	att := s.Node
+	if miner == att {
+		delta := len(att.BlockChain) - 1 - pubLen
+		s.branchLen++
+		l.Evil("Selfish: attacker [%s] mined block %d and keeps it private, lead %d", att.Name, len(att.BlockChain)-1, delta+1)
+		if delta == 0 && s.branchLen == 2 {
+			l.Evil("Selfish: attacker wins the race and publishes private branch")
+			rm.selfishRelease(len(att.BlockChain), nil, l)
+			s.branchLen = 0
+		}
+		rm.SyncWallets(l)
+		return
+	}
+
+	delta := len(att.BlockChain) - pubLen
+	switch {
+	case delta <= 0:
+		// attacker is not ahead: honest block wins, attacker adopts public chain
+		rm.selfishRelease(0, miner, l)
+		if len(miner.BlockChain) > len(att.BlockChain) {
+			if _, err := att.ReceiveChain(miner.BlockChain); err != nil {
+				l.Error("Selfish: attacker failed to adopt public chain: %s", err)
+			}
+		}
+		s.branchLen = 0
+		s.published = len(att.BlockChain)
+	case delta == 1:
+		l.Evil("Selfish: honest block found, attacker publishes its last block and starts the race")
+		rm.selfishRelease(len(att.BlockChain), miner, l)
+	case delta == 2:
+		l.Evil("Selfish: honest block found, attacker publishes %d blocks and overrides it", s.Withheld())
+		rm.selfishRelease(len(att.BlockChain), miner, l)
+		s.branchLen = 0
+	default:
+		l.Evil("Selfish: honest block found, attacker is far ahead and publishes one block")
+		rm.selfishRelease(pubLen+1, miner, l)
+	}
+	rm.SyncWallets(l)
+}
+
+// Publishes attacker chain up to length upTo (0 - nothing) and delivers block of honest finder.
+// Honest nodes receive attacker chain first with probability Gamma
+func (rm *RuscoinMngr) selfishRelease(upTo int, finder *ruscoin.Node, l EmuLogger) {
+	s := rm.Selfish
+	var private []*ruscoin.Block
+	if upTo > 0 {
+		private = s.Node.BlockChain[:upTo]
+		s.published = max(s.published, upTo)
+	}
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		if n == s.Node || n == finder {
+			continue
+		}
+		attackerFirst := finder == nil || rm.Rand.Float64() < s.Gamma
+		if private != nil && attackerFirst {
+			rm.deliverChain(private, n, l)
+		}
+		if finder != nil {
+			rm.deliverChain(finder.BlockChain, n, l)
+		}
+		if private != nil && !attackerFirst {
+			rm.deliverChain(private, n, l)
+		}
+	}
+	if private != nil && finder != nil {
+		rm.deliverChain(private, finder, l)
+	}
+}
+
+// Appends revenue point for the current public chain
+func (s *SelfishMiner) record(rm *RuscoinMngr) {
+	p := RevenuePoint{Tick: rm.Tick}
+	if n := rm.PublicNode(); n != nil {
+		for _, b := range n.BlockChain[min(s.StartLen, len(n.BlockChain)):] {
+			if BlockMiner(b) == s.Node.Wallet.Addr {
+				p.Attacker++
+			} else {
+				p.Honest++
+			}
+		}
+	}
+	total := 0
+	for _, n := range rm.Nodes {
+		if !n.Offline {
+			total += n.HashPower
+		}
+	}
+	if total > 0 && !s.Node.Offline {
+		p.HashShare = 100 * float64(s.Node.HashPower) / float64(total)
+	}
+	s.History = append(s.History, p)
+}
+
+// Address of the miner rewarded by the block
+func BlockMiner(b *ruscoin.Block) string {
+	if len(b.Body.Transactions) == 0 {
+		return ""
+	}
+	for _, u := range b.Body.Transactions[0].OutputUtxo.SortedItems() {
+		if u.Addr != ruscoin.COINBASE_ADDR {
+			return u.Addr
+		}
+	}
+	return ""
+}
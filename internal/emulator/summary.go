@@ -0,0 +1,239 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Emulation state summary for headless runs
+type RunSummary struct {
+	Ticks int
+	Nodes []NodeSummary
+	// Distinct chain tips, the longest first
+	Tips []TipSummary
+	// Number of chain tips except the main one
+	Forks int
+	// User transactions in the longest chain
+	Transactions int
+	// Transactions left in mempool of the longest chain node
+	Pending int
+	// Average size of the longest chain blocks as share of block size limit, percents
+	Fullness    float64
+	Wallets     []WalletSummary
+	Rejected    []RejectedBlock
+	Selfish     *SelfishSummary     `json:",omitempty"`
+	DoubleSpend *DoubleSpendSummary `json:",omitempty"`
+}
+
+type DoubleSpendSummary struct {
+	Node          string
+	Victim        string
+	Amount        int
+	Confirmations int
+	// Percents
+	HashShare float64
+	// released, gave up, aborted or empty while running
+	Result   string
+	Reversed bool
+	// Payment confirmations at the end of the attack
+	FinalConfirmations int
+	// Confirmations with attacker success probability below DOUBLE_SPEND_RISK, -1 - none
+	SafeConfirmations int
+}
+
+type SelfishSummary struct {
+	Node string
+	// Blocks in public chain since attack start
+	Attacker int
+	Honest   int
+	// Percents
+	HashShare    float64
+	RevenueShare float64
+}
+
+type NodeSummary struct {
+	Name    string
+	Id      string
+	Height  int
+	Tip     string
+	Offline bool
+}
+
+type TipSummary struct {
+	Hash   string
+	Height int
+	Nodes  []string
+}
+
+type WalletSummary struct {
+	Name    string
+	Addr    string
+	Balance int
+}
+
+func (rm *RuscoinMngr) Summary() RunSummary {
+	s := RunSummary{
+		Ticks:    rm.Tick,
+		Rejected: slices.Clone(rm.Rejected),
+	}
+	tips := make(map[string]*TipSummary)
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		ns := NodeSummary{
+			Name:    n.Name,
+			Id:      n.Id,
+			Height:  len(n.BlockChain) - 1,
+			Offline: n.Offline,
+		}
+		if b := n.GetLastBlock(); b != nil {
+			ns.Tip = b.HashString()
+			if _, ok := tips[ns.Tip]; !ok {
+				tips[ns.Tip] = &TipSummary{Hash: ns.Tip, Height: ns.Height}
+			}
+			tips[ns.Tip].Nodes = append(tips[ns.Tip].Nodes, n.Name)
+		}
+		s.Nodes = append(s.Nodes, ns)
+	}
+	slices.SortFunc(s.Nodes, func(a, b NodeSummary) int { return strings.Compare(a.Name, b.Name) })
+
+	for _, t := range tips {
+		slices.Sort(t.Nodes)
+		s.Tips = append(s.Tips, *t)
+	}
+	slices.SortFunc(s.Tips, func(a, b TipSummary) int {
+		if a.Height != b.Height {
+			return b.Height - a.Height
+		}
+		return strings.Compare(a.Hash, b.Hash)
+	})
+	if len(s.Tips) > 1 {
+		s.Forks = len(s.Tips) - 1
+	}
+
+	if best := rm.BestPeer(""); best != nil {
+		for _, b := range best.BlockChain {
+			// first transaction is miner reward
+			if len(b.Body.Transactions) > 1 {
+				s.Transactions += len(b.Body.Transactions) - 1
+			}
+			s.Fullness += b.Fullness()
+		}
+		if len(best.BlockChain) > 0 {
+			s.Fullness /= float64(len(best.BlockChain))
+		}
+		s.Pending = len(best.Mempool)
+	}
+
+	for _, a := range rm.WalletAddrs() {
+		w := rm.Wallets[a]
+		s.Wallets = append(s.Wallets, WalletSummary{Name: w.Name, Addr: w.Addr, Balance: w.Balance()})
+	}
+	slices.SortFunc(s.Wallets, func(a, b WalletSummary) int { return strings.Compare(a.Name, b.Name) })
+
+	if sm := rm.Selfish; sm != nil {
+		p := sm.Last()
+		s.Selfish = &SelfishSummary{
+			Node:         sm.Node.Name,
+			Attacker:     p.Attacker,
+			Honest:       p.Honest,
+			HashShare:    p.HashShare,
+			RevenueShare: p.Share(),
+		}
+	}
+	if ds := rm.DoubleSpend; ds != nil {
+		s.DoubleSpend = &DoubleSpendSummary{
+			Node:               ds.Node.Name,
+			Victim:             ds.Victim.Name,
+			Amount:             ds.Amount,
+			Confirmations:      ds.Confirmations,
+			HashShare:          100 * ds.HashShare,
+			Result:             ds.Result,
+			Reversed:           ds.Reversed,
+			FinalConfirmations: ds.FinalConfirmations,
+			SafeConfirmations:  SafeConfirmations(ds.HashShare, DOUBLE_SPEND_RISK),
+		}
+	}
+	return s
+}
+
+// Writes human readable summary
+func (s RunSummary) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Ticks:\t%d\n", s.Ticks)
+	fmt.Fprintf(tw, "Forks:\t%d\n", s.Forks)
+	fmt.Fprintf(tw, "Transactions:\t%d\n", s.Transactions)
+	fmt.Fprintf(tw, "Pending:\t%d\n", s.Pending)
+	fmt.Fprintf(tw, "Block fullness:\t%.1f%%\n", s.Fullness)
+
+	fmt.Fprintf(tw, "\nNODE\tHEIGHT\tTIP\tSTATUS\n")
+	for _, n := range s.Nodes {
+		st := "online"
+		if n.Offline {
+			st = "offline"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", n.Name, n.Height, shortHash(n.Tip), st)
+	}
+
+	fmt.Fprintf(tw, "\nTIP\tHEIGHT\tNODES\n")
+	for _, t := range s.Tips {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", shortHash(t.Hash), t.Height, strings.Join(t.Nodes, ", "))
+	}
+
+	fmt.Fprintf(tw, "\nWALLET\tBALANCE\tADDRESS\n")
+	for _, wl := range s.Wallets {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", wl.Name, wl.Balance, wl.Addr)
+	}
+
+	if sm := s.Selfish; sm != nil {
+		fmt.Fprintf(tw, "\nSELFISH MINER:\t%s\n", sm.Node)
+		fmt.Fprintf(tw, "Hash share:\t%.1f%%\n", sm.HashShare)
+		fmt.Fprintf(tw, "Revenue share:\t%.1f%% (%d of %d blocks)\n", sm.RevenueShare, sm.Attacker, sm.Attacker+sm.Honest)
+	}
+
+	if ds := s.DoubleSpend; ds != nil {
+		res := ds.Result
+		if res == "" {
+			res = "running"
+		}
+		fmt.Fprintf(tw, "\nDOUBLE SPEND:\t%s pays %d to %s, victim waits %d confirmations\n", ds.Node, ds.Amount, ds.Victim, ds.Confirmations)
+		fmt.Fprintf(tw, "Hash share:\t%.1f%%\n", ds.HashShare)
+		fmt.Fprintf(tw, "Result:\t%s, reversed: %t, confirmations: %d\n", res, ds.Reversed, ds.FinalConfirmations)
+		if ds.SafeConfirmations < 0 {
+			fmt.Fprintf(tw, "Safe confirmations:\tnone, attacker has majority\n")
+		} else {
+			fmt.Fprintf(tw, "Safe confirmations:\t%d (risk below %g)\n", ds.SafeConfirmations, DOUBLE_SPEND_RISK)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nREJECTED BLOCKS: %d\n", len(s.Rejected))
+	if len(s.Rejected) > 0 {
+		fmt.Fprintf(tw, "TICK\tNODE\tHEIGHT\tHASH\tREASON\n")
+		for _, r := range s.Rejected {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", r.Tick, r.Node, r.Height, shortHash(r.Hash), strings.ReplaceAll(r.Reason, "\n", " "))
+		}
+	}
+	return tw.Flush()
+}
+
+func shortHash(h string) string {
+	if len(h) > 16 {
+		return h[:16]
+	}
+	return h
+}
+
+// Writes blockchain of the node with the longest chain as JSON.
+// Runs with the same seed write identical output
+func (rm *RuscoinMngr) WriteChain(w io.Writer) error {
+	best := rm.BestPeer("")
+	if best == nil {
+		return fmt.Errorf("RuscoinMngr: no online nodes")
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(best.BlockChain)
+}
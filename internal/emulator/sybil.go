@@ -0,0 +1,263 @@
+package emulator
+
+import (
+	"fmt"
+	"myruscoint/internal/ruscoin"
+	"slices"
+)
+
+// What Sybil nodes do with intercepted transactions
+const (
+	// Drop transaction
+	SYBIL_CENSOR = "censor"
+	// Relay transaction after Delay ticks
+	SYBIL_DELAY = "delay"
+	// Keep transaction until the attack is stopped
+	SYBIL_WITHHOLD = "withhold"
+)
+
+var SybilModes = []string{SYBIL_CENSOR, SYBIL_DELAY, SYBIL_WITHHOLD}
+
+// Settings of Sybil attack
+type SybilConfig struct {
+	// Party owning Sybil nodes, used as node name prefix
+	Owner string
+	Count int
+	// Sybil links of every victim node
+	PerVictim int
+	// Honest node ids to surround, all honest nodes if empty
+	Victims []string
+	// Victims drop links to honest nodes, Sybils take all their peer slots
+	Exclusive bool
+	Mode      string
+	Delay     int
+	// Addresses whose transactions are intercepted, all transactions if empty
+	Targets []string
+}
+
+// Many lightweight nodes of one owner. They do not mine, relay blocks honestly
+// and intercept relay of selected transactions
+type SybilAttack struct {
+	SybilConfig
+	Nodes []*ruscoin.Node
+	// Number of intercepted transactions
+	Censored int
+	Delayed  int
+	Withheld int
+	held     []heldTx
+	// Honest links removed from exclusive victims
+	removed [][2]*ruscoin.Node
+}
+
+type heldTx struct {
+	Node *ruscoin.Node
+	T    ruscoin.Transaction
+	// Tick to relay transaction, 0 - when attack stops
+	Release int
+}
+
+func (c SybilConfig) Validate() error {
+	if c.Owner == "" {
+		return fmt.Errorf("Sybil: owner is empty")
+	}
+	if c.Count < 1 || c.Count > SYBIL_MAX {
+		return fmt.Errorf("Sybil: number of nodes must be in range 1..%d", SYBIL_MAX)
+	}
+	if c.PerVictim < 1 || c.PerVictim > c.Count {
+		return fmt.Errorf("Sybil: links per victim must be in range 1..%d", c.Count)
+	}
+	if !slices.Contains(SybilModes, c.Mode) {
+		return fmt.Errorf("Sybil: unknown mode %s", c.Mode)
+	}
+	if c.Mode == SYBIL_DELAY && c.Delay < 1 {
+		return fmt.Errorf("Sybil: delay must be positive")
+	}
+	return nil
+}
+
+const SYBIL_MAX = 100
+
+// Spawns Sybil nodes and connects them to victims
+func (rm *RuscoinMngr) StartSybil(c SybilConfig, l EmuLogger) (*SybilAttack, error) {
+	if rm.Sybil != nil {
+		return nil, fmt.Errorf("Sybil: attack of %s is already running", rm.Sybil.Owner)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if rm.Tick == 0 {
+		return nil, fmt.Errorf("Sybil: attack can be started after genesis block")
+	}
+	victims := []*ruscoin.Node{}
+	for _, id := range rm.NodeIds() {
+		if len(c.Victims) == 0 || slices.Contains(c.Victims, id) {
+			victims = append(victims, rm.Nodes[id])
+		}
+	}
+	for _, id := range c.Victims {
+		if _, err := rm.GetNode(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(victims) == 0 {
+		return nil, fmt.Errorf("Sybil: no victim nodes")
+	}
+	pub := rm.PublicNode()
+	if pub == nil {
+		return nil, fmt.Errorf("Sybil: no online nodes")
+	}
+
+	// Sybil links switch network from full mesh to links mode, honest nodes keep talking to each other
+	if rm.FullMesh() {
+		ids := rm.NodeIds()
+		for i, a := range ids {
+			for _, b := range ids[i+1:] {
+				rm.Link(a, b)
+			}
+		}
+	}
+	s := &SybilAttack{SybilConfig: c}
+	for i := 1; i <= c.Count; i++ {
+		n, err := rm.NewNode(fmt.Sprintf("%s-%d", c.Owner, i))
+		if err != nil {
+			return nil, err
+		}
+		n.HashPower = 0
+		// Sybil nodes do not hold coins, their wallets are not listed
+		delete(rm.Wallets, n.Wallet.Addr)
+		if _, err = n.ReceiveChain(pub.BlockChain); err != nil {
+			return nil, err
+		}
+		rm.Owners[n.Id] = c.Owner
+		s.Nodes = append(s.Nodes, n)
+	}
+	// every Sybil node keeps one honest peer, outside the victims if possible, to follow the chain
+	others := []string{}
+	for _, id := range rm.NodeIds() {
+		if rm.Owners[id] == "" && !slices.Contains(victims, rm.Nodes[id]) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		for _, v := range victims {
+			others = append(others, v.Id)
+		}
+	}
+	for _, n := range s.Nodes {
+		rm.Link(n.Id, others[rm.Rand.Intn(len(others))])
+	}
+	for _, v := range victims {
+		if c.Exclusive {
+			for _, id := range rm.NodeIds() {
+				if m := rm.Nodes[id]; v.Neighbours[id] != nil && rm.Owners[id] == "" {
+					rm.Unlink(v.Id, id)
+					s.removed = append(s.removed, [2]*ruscoin.Node{v, m})
+				}
+			}
+		}
+		for _, k := range rm.Rand.Perm(c.Count)[:c.PerVictim] {
+			rm.Link(v.Id, s.Nodes[k].Id)
+		}
+	}
+	rm.Sybil = s
+	l.Evil("Sybil: %s spawned %d nodes around %d victims, mode %s", c.Owner, c.Count, len(victims), c.Mode)
+	return s, nil
+}
+
+// Releases withheld transactions, removes Sybil nodes and restores victim links
+func (rm *RuscoinMngr) StopSybil(l EmuLogger) error {
+	s := rm.Sybil
+	if s == nil {
+		return fmt.Errorf("Sybil: attack is not running")
+	}
+	for _, h := range s.held {
+		rm.relayTransaction(h.Node, &h.T, true, l)
+	}
+	rm.Sybil = nil
+	for _, n := range s.Nodes {
+		if _, ok := rm.Nodes[n.Id]; ok {
+			rm.RemoveNode(n.Id)
+		}
+	}
+	for _, p := range s.removed {
+		if _, ok := rm.Nodes[p[0].Id]; ok {
+			if _, ok = rm.Nodes[p[1].Id]; ok {
+				rm.Link(p[0].Id, p[1].Id)
+			}
+		}
+	}
+	l.OK("Sybil: %s nodes removed, %d held transactions released", s.Owner, len(s.held))
+	return nil
+}
+
+// Node is Sybil and transaction is selected for interception
+func (s *SybilAttack) intercepts(n *ruscoin.Node, t *ruscoin.Transaction) bool {
+	if s == nil || !slices.Contains(s.Nodes, n) {
+		return false
+	}
+	if len(s.Targets) == 0 {
+		return true
+	}
+	for _, u := range t.InputUtxo {
+		if slices.Contains(s.Targets, u.Addr) {
+			return true
+		}
+	}
+	for _, u := range t.OutputUtxo {
+		if slices.Contains(s.Targets, u.Addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies attack mode to transaction intercepted by Sybil node n
+func (s *SybilAttack) hold(rm *RuscoinMngr, n *ruscoin.Node, t *ruscoin.Transaction, l EmuLogger) {
+	switch s.Mode {
+	case SYBIL_CENSOR:
+		s.Censored++
+		l.Evil("Sybil: node [%s] drops transaction", n.Name)
+	case SYBIL_DELAY:
+		s.Delayed++
+		s.held = append(s.held, heldTx{Node: n, T: t.Clone(), Release: rm.Tick + s.Delay})
+		l.Evil("Sybil: node [%s] delays transaction for %d ticks", n.Name, s.Delay)
+	case SYBIL_WITHHOLD:
+		s.Withheld++
+		s.held = append(s.held, heldTx{Node: n, T: t.Clone()})
+		l.Evil("Sybil: node [%s] withholds transaction", n.Name)
+	}
+}
+
+// Relays delayed transactions whose time has come
+func (rm *RuscoinMngr) sybilTick(l EmuLogger) {
+	s := rm.Sybil
+	keep := s.held[:0]
+	for _, h := range s.held {
+		if h.Release > 0 && rm.Tick >= h.Release {
+			l.Evil("Sybil: node [%s] relays delayed transaction", h.Node.Name)
+			rm.relayTransaction(h.Node, &h.T, true, l)
+			continue
+		}
+		keep = append(keep, h)
+	}
+	s.held = keep
+}
+
+// Number of transactions held by Sybil nodes
+func (s *SybilAttack) Held() int {
+	return len(s.held)
+}
+
+// Share of node neighbours owned by Sybil attacker, 0..1
+func (rm *RuscoinMngr) SybilShare(n *ruscoin.Node) float64 {
+	if len(n.Neighbours) == 0 {
+		return 0
+	}
+	cnt := 0
+	for id := range n.Neighbours {
+		if rm.Owners[id] != "" {
+			cnt++
+		}
+	}
+	return float64(cnt) / float64(len(n.Neighbours))
+}
@@ -0,0 +1,194 @@
+package emulator
+
+import (
+	"fmt"
+	"maps"
+	"myruscoint/internal/ruscoin"
+	"slices"
+	"time"
+)
+
+// Runs single emulation tick: genesis block on the first tick, then mining
+// by the main node and sending new block to other nodes
+func (rm *RuscoinMngr) RunTick(l EmuLogger) error {
+	if len(rm.Nodes) == 0 {
+		l.Error("No Nodes exists. Aborting TICK operation")
+		return nil
+	}
+	if rm.Tick == 0 {
+		l.Info("First tick: initiating GENESIS block")
+		return rm.tickGenesis(l)
+	}
+	return rm.tickGeneral(l)
+}
+
+func (rm *RuscoinMngr) tickGenesis(l EmuLogger) error {
+	logTitle := "Genesis Block: "
+	if rm.MainNode() == nil {
+		l.Info(logTitle + "no main node selected. Selecting Main node.")
+		rm.SelectMainNode()
+		l.MinerUpdate()
+		rm.pause()
+	}
+	n := rm.MainNode()
+	if n == nil {
+		l.Error(logTitle + "no online nodes : ABORTING")
+		return fmt.Errorf("%sno online nodes", logTitle)
+	}
+	l.Info("Node %s: Creating genesis block candidate", n.Name)
+	gb, err := n.InitGenesisBlock()
+	if err != nil {
+		l.Error(logTitle + err.Error() + " : ABORTING")
+		return err
+	}
+	for _, addr := range slices.Sorted(maps.Keys(rm.GenesisAlloc)) {
+		gb.Body.Transactions[0].OutputUtxo.NewRecord(addr, rm.GenesisAlloc[addr])
+		l.Info(logTitle+"%d coins allocated to %s", rm.GenesisAlloc[addr], addr)
+	}
+
+	rm.pause()
+
+	l.OK(logTitle + "genesis block candidate created")
+	l.Info(logTitle + "Start block mining")
+	t := time.Now()
+	b, err := rm.Mine()
+	d := time.Since(t)
+	if err != nil {
+		l.Error("%sMine fialed: %s", logTitle, err)
+		return err
+	}
+
+	rm.Tick++
+
+	l.OK(logTitle+"Genesis block mined succesfully in %2.f seconds", d.Seconds())
+
+	l.NodeUpdate(n.Id)
+
+	l.Info("Sending genesis block to other nodes")
+	for _, id := range rm.NodeIds() {
+		nn := rm.Nodes[id]
+		if nn.Offline {
+			l.Info("Node [%s]: offline, skipping", nn.Name)
+			continue
+		}
+		if id != n.Id {
+			l.Info("Node [%s]: evaluate new block", nn.Name)
+			if err = nn.AddVerifyBlock(b); err != nil {
+				rm.recordRejected(nn, b, err)
+				l.Error(err.Error())
+			} else {
+				l.OK("Node [%s]: new block added", nn.Name)
+			}
+			l.NodeUpdate(nn.Id)
+		}
+	}
+	l.OK(logTitle + "Genesis block add succesfully")
+	rm.SyncWallets(l)
+
+	rm.pause()
+	rm.SelectMainNode()
+	l.MinerUpdate()
+	return nil
+}
+
+func (rm *RuscoinMngr) tickGeneral(l EmuLogger) error {
+	logPrefix := fmt.Sprintf("New Tick (%d): ", rm.Tick+1)
+
+	ferr := func(msg string) error {
+		l.Error(msg)
+		return fmt.Errorf(logPrefix+"%s", msg)
+	}
+
+	l.Info(logPrefix + "Starting")
+
+	n := rm.MainNode()
+	if n == nil {
+		return ferr("No Miner node selected. Aborting new tick.")
+	}
+	pubLen := rm.publicLen()
+	if rm.DoubleSpend.Running() {
+		rm.doubleSpendPrepare(n)
+	}
+	rm.Behaviour(n).PrepareBlock(rm, n, l)
+	l.Info(logPrefix+"Node [%s] start mining...", n.Name)
+	t := time.Now()
+	b, err := rm.Mine()
+	switch {
+	case err != nil && b != nil && rm.Behaviour(n).Kind() != BEHAVIOUR_HONEST:
+		// block of a dishonest miner may break rules the miner checks itself, e.g. time
+		rm.recordRejected(n, b, err)
+		l.Error(logPrefix+"Node [%s] rejected own block: %s", n.Name, err)
+	case err != nil:
+		return ferr(err.Error())
+	default:
+		l.OK(logPrefix+"Node [%s] finished mining in %.2f seconds", n.Name, time.Since(t).Seconds())
+	}
+	l.NodeUpdate(n.Id)
+
+	switch {
+	case err != nil:
+		// no block to deliver
+	case rm.Selfish != nil:
+		rm.selfishTick(n, pubLen, l)
+	case rm.DoubleSpend.Running():
+		rm.doubleSpendTick(n, l)
+	case rm.Behaviour(n).Publish(rm, n, l):
+		l.Info(logPrefix + "Sending block to other Nodes")
+		rm.propagate(n, l)
+	}
+	rm.behaviourTick(l)
+
+	rm.Tick++
+	rm.censorTick(n, err == nil, l)
+	if rm.Selfish != nil {
+		rm.Selfish.record(rm)
+	}
+	rm.healExpired(l)
+	if rm.Sybil != nil {
+		rm.sybilTick(l)
+	}
+	rm.SyncWallets(l)
+
+	rm.SelectMainNode()
+	l.MinerUpdate()
+	return nil
+}
+
+// Sends chain to node. Node switches to it if it is longer than its own one.
+// Returns if chain was accepted and number of blocks removed by reorganisation
+func (rm *RuscoinMngr) deliverChain(chain []*ruscoin.Block, to *ruscoin.Node, l EmuLogger) (bool, int) {
+	if to.Offline {
+		l.Info("Node [%s] offline, skipping", to.Name)
+		return false, 0
+	}
+	if len(chain) <= len(to.BlockChain) {
+		l.Info("Node [%s]: received chain is not longer, keeping own", to.Name)
+		return false, 0
+	}
+	removed, err := to.ReceiveChain(chain)
+	if err != nil {
+		rm.recordRejected(to, chain[len(chain)-1], err)
+		l.Error("Node [%s]: %s", to.Name, err)
+		return false, 0
+	}
+	if removed > 0 {
+		l.Evil("Node [%s]: chain reorganisation, %d blocks replaced", to.Name, removed)
+	} else {
+		l.OK("Node [%s]: block accepted", to.Name)
+	}
+	l.NodeUpdate(to.Id)
+	return true, removed
+}
+
+// Adds pause between node operations, see TakePause
+func (rm *RuscoinMngr) pause() {
+	rm.pauseDue += rm.OpPause
+}
+
+// Returns and resets pauses accumulated by operations. Callers holding a lock sleep
+// after releasing it, so that clients are not blocked during the pause
+func (rm *RuscoinMngr) TakePause() time.Duration {
+	d := rm.pauseDue
+	rm.pauseDue = 0
+	return d
+}
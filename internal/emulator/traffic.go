@@ -0,0 +1,73 @@
+package emulator
+
+import (
+	"myruscoint/internal/ruscoin"
+	"slices"
+)
+
+// Creates up to count random transactions between wallets and sends them to the network.
+// Every transaction spends part of one random utxo and pays random fee up to maxFee.
+// Returns number of accepted transactions
+func (rm *RuscoinMngr) RandomTransactions(count, maxFee int, l EmuLogger) int {
+	n := rm.MainNode()
+	if n == nil || len(rm.Wallets) < 2 {
+		return 0
+	}
+	addrs := rm.WalletAddrs()
+	accepted := 0
+	for i := 0; i < count; i++ {
+		from := rm.Wallets[addrs[rm.Rand.Intn(len(addrs))]]
+		if from.Offline || len(from.Utxo) == 0 {
+			continue
+		}
+		// double-spend attacker keeps coins of the attack untouched
+		if ds := rm.DoubleSpend; ds.Running() && from == ds.Node.Wallet {
+			continue
+		}
+		to := addrs[rm.Rand.Intn(len(addrs))]
+		if to == from.Addr {
+			continue
+		}
+		uids := make([]string, 0, len(from.Utxo))
+		for id := range from.Utxo {
+			uids = append(uids, id)
+		}
+		slices.Sort(uids)
+		uid := uids[rm.Rand.Intn(len(uids))]
+		u := from.Utxo[uid]
+		amount := 1 + rm.Rand.Intn(u.Amount)
+		fee := 0
+		if maxFee > 0 && amount > 1 {
+			fee = rm.Rand.Intn(min(maxFee, amount-1) + 1)
+		}
+
+		// payment and change, fee is taken from the payment
+		t := ruscoin.NewTransaction()
+		t.InputUtxo.Put(uid, u.Addr, u.Amount)
+		t.OutputUtxo.NewRecord(rm.Wallets[to].ReceiveAddr(), amount-fee)
+		if d := u.Amount - amount; d > 0 {
+			t.OutputUtxo.NewRecord(from.ChangeAddr(), d)
+		}
+		if err := from.SignTransaction(t); err != nil {
+			l.Error("Traffic: %s", err)
+			continue
+		}
+		if err := rm.SubmitTransaction(from, t, l); err != nil {
+			l.Error("Traffic: %s", err)
+			continue
+		}
+		l.Info("Traffic: %s sends %d to %s, fee %d", from.Name, amount-fee, rm.Wallets[to].Name, fee)
+		accepted++
+	}
+	return accepted
+}
+
+// Sorted addresses of all wallets
+func (rm *RuscoinMngr) WalletAddrs() []string {
+	addrs := make([]string, 0, len(rm.Wallets))
+	for a := range rm.Wallets {
+		addrs = append(addrs, a)
+	}
+	slices.Sort(addrs)
+	return addrs
+}
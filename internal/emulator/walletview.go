@@ -0,0 +1,134 @@
+package emulator
+
+import (
+	"cmp"
+	"slices"
+
+	"myruscoint/internal/ruscoin"
+)
+
+// Confirmations reward output needs until wallet view counts it as spendable. Rewards of
+// the last blocks vanish if the block loses a fork. Nodes do not enforce maturity
+const REWARD_MATURITY = 3
+
+// Wallet utxo as seen by a node
+type WalletUtxo struct {
+	Id     string
+	Addr   string
+	Amount int
+	// Number of blocks from the block with the utxo to node tip, 0 for mempool output
+	Depth int
+	// Output of reward transaction
+	Reward bool
+	// Spent by mempool transaction
+	Spending bool
+	// Change of mempool transaction sent by the wallet
+	Change bool
+}
+
+func (u *WalletUtxo) Immature() bool {
+	return u.Reward && u.Depth < REWARD_MATURITY
+}
+
+// Wallet balances relative to node chain and mempool
+type WalletView struct {
+	Wallet *ruscoin.Wallet
+	Node   *ruscoin.Node
+	// Height of node tip
+	Height int
+	// Wallet utxo in node utxo set
+	Confirmed int
+	// Part of confirmed: rewards with less than REWARD_MATURITY confirmations
+	Immature int
+	// Outputs of mempool transactions paying to the wallet, including change, which are
+	// not spent by other mempool transactions
+	PendingIn int
+	// Confirmed utxo spent by mempool transactions
+	PendingOut int
+	// Confirmed utxo, the deepest first, then mempool outputs
+	Utxo []WalletUtxo
+}
+
+// Confirmed mature utxo not spent by mempool transactions
+func (v *WalletView) Available() int {
+	a := 0
+	for _, u := range v.Utxo {
+		if u.Depth > 0 && !u.Immature() && !u.Spending {
+			a += u.Amount
+		}
+	}
+	return a
+}
+
+// Balances of the wallet as node n sees them
+func (rm *RuscoinMngr) WalletView(w *ruscoin.Wallet, n *ruscoin.Node) *WalletView {
+	v := &WalletView{Wallet: w, Node: n, Height: len(n.BlockChain) - 1}
+	spending := map[string]bool{}
+	for _, t := range n.Mempool {
+		for id := range t.InputUtxo {
+			spending[id] = true
+		}
+	}
+
+	ul := w.OwnUtxo(n.Utxo)
+	heights, rewards := n.UtxoHeights(ul)
+	for id, u := range ul.SortedItems() {
+		wu := WalletUtxo{
+			Id:       id,
+			Addr:     u.Addr,
+			Amount:   u.Amount,
+			Depth:    v.Height - heights[id] + 1,
+			Reward:   rewards[id],
+			Spending: spending[id],
+		}
+		v.Confirmed += u.Amount
+		if wu.Immature() {
+			v.Immature += u.Amount
+		}
+		if wu.Spending {
+			v.PendingOut += u.Amount
+		}
+		v.Utxo = append(v.Utxo, wu)
+	}
+	slices.SortStableFunc(v.Utxo, func(a, b WalletUtxo) int {
+		return cmp.Compare(b.Depth, a.Depth)
+	})
+
+	for _, t := range n.Mempool {
+		sent := len(w.OwnUtxo(t.InputUtxo)) > 0
+		for id, u := range t.OutputUtxo.SortedItems() {
+			if !w.Owns(u.Addr) {
+				continue
+			}
+			if !spending[id] {
+				v.PendingIn += u.Amount
+			}
+			v.Utxo = append(v.Utxo, WalletUtxo{Id: id, Addr: u.Addr, Amount: u.Amount, Spending: spending[id], Change: sent})
+		}
+	}
+	return v
+}
+
+// Coins the wallet may spend through node n: wallet utxo not spent by mempool transactions
+// and unspent change of wallet transactions waiting in the mempool
+func (rm *RuscoinMngr) SpendableUtxo(w *ruscoin.Wallet, n *ruscoin.Node) ruscoin.UtxoList {
+	if n == nil {
+		return w.Utxo
+	}
+	v := rm.WalletView(w, n)
+	spending := map[string]bool{}
+	res := ruscoin.NewUtxoList()
+	for _, u := range v.Utxo {
+		if u.Spending {
+			spending[u.Id] = true
+		} else if u.Change {
+			res.Put(u.Id, u.Addr, u.Amount)
+		}
+	}
+	for id, u := range w.Utxo {
+		if !spending[id] {
+			res.Put(id, u.Addr, u.Amount)
+		}
+	}
+	return res
+}
@@ -2,9 +2,12 @@ package emulator
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"myruscoint/internal/ruscoin"
 	"myruscoint/views"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
@@ -14,13 +17,17 @@ import (
 )
 
 func (wb *EmulatorWeb) HandleIndex(c echo.Context) error {
-	return renderTempl(c, views.Index(strconv.Itoa(wb.RcMngr.Tick)))
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(c, views.Index(strconv.Itoa(wb.RcMngr.Tick), wb.schedulerToItem()))
 }
 
 func (wb *EmulatorWeb) HandleTest(c echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogInfoSend("Test pressed")
 	n := wb.RcMngr.GetSetMainNode()
-	wb.RssSendMainMinerUpdates(n.Name)
+	wb.RssSendMainMinerUpdates(n.Id)
 	wb.RssLogInfoSend(fmt.Sprintf("Node %s: Creating block candidate", n.Name))
 	_, err := n.InitGenesisBlock()
 	if err != nil {
@@ -73,6 +80,8 @@ func (wb *EmulatorWeb) HandleSse(c echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleNodeList(c echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	ncList := make([]views.NodeCellInput, len(wb.RcMngr.Nodes))
 	i := 0
 	minerId := ""
@@ -88,6 +97,22 @@ func (wb *EmulatorWeb) HandleNodeList(c echo.Context) error {
 		n.WAddress = node.Wallet.Addr
 		n.WCoins = strconv.Itoa(node.Wallet.Balance())
 		n.Miner = n.Id == minerId
+		n.Offline = node.Offline
+		n.HashPower = strconv.Itoa(node.HashPower)
+		n.Selfish = wb.RcMngr.Selfish != nil && wb.RcMngr.Selfish.Node == node
+		n.Owner = wb.RcMngr.Owners[node.Id]
+		bh := wb.RcMngr.Behaviour(node)
+		n.Behaviour = bh.Kind()
+		n.BehaviourDesc = bh.String()
+		n.Behaviours = BehaviourKinds
+		n.ClockSkew = strconv.Itoa(int(node.ClockSkew.Seconds()))
+		n.MTP = "-"
+		if len(node.BlockChain) > 0 {
+			n.MTP = node.MedianTimePast().Format(time.DateTime)
+		}
+		if share := wb.RcMngr.SybilShare(node); share > 0 && n.Owner == "" {
+			n.SybilShare = strconv.FormatFloat(100*share, 'f', 0, 64)
+		}
 
 		b := node.GetLastBlock()
 		if b != nil {
@@ -100,9 +125,6 @@ func (wb *EmulatorWeb) HandleNodeList(c echo.Context) error {
 		ncList[i] = n
 		i++
 	}
-	if err := renderTempl(c, views.NodeCellList(ncList)); err != nil {
-		log.Printf("ERROR: Node cell list: %s", err)
-	}
 	slices.SortFunc(ncList, func(a, b views.NodeCellInput) int {
 		if a.Name > b.Name {
 			return 1
@@ -111,10 +133,15 @@ func (wb *EmulatorWeb) HandleNodeList(c echo.Context) error {
 		}
 		return 0
 	})
+	if err := renderTempl(c, views.NodeCellList(ncList)); err != nil {
+		log.Printf("ERROR: Node cell list: %s", err)
+	}
 	return nil
 }
 
 func (wb *EmulatorWeb) HandleBlockDetails(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	nId := ctx.FormValue("node")
 	bh := ctx.FormValue("block")
 	h, err := strconv.Atoi(bh)
@@ -133,6 +160,8 @@ func (wb *EmulatorWeb) HandleBlockDetails(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleBlockTransactions(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	nId := ctx.FormValue("node")
 	bh := ctx.FormValue("block")
 	h, err := strconv.Atoi(bh)
@@ -153,6 +182,9 @@ func (wb *EmulatorWeb) HandleBlockTransactions(ctx echo.Context) error {
 			InputUtxo:  make([]views.UtxoItem, len(tr.InputUtxo)),
 			OutputUtxo: make([]views.UtxoItem, len(tr.OutputUtxo)),
 		}
+		if i > 0 && b.Header.Height > 0 {
+			t.Fee = strconv.Itoa(tr.Fee())
+		}
 		j := 0
 		for _, u := range tr.InputUtxo.SortedItems() {
 			t.InputUtxo[j] = views.UtxoItem{
@@ -175,6 +207,8 @@ func (wb *EmulatorWeb) HandleBlockTransactions(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleMinerSelect(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogInfoSend("Selecting new Miner")
 	wb.RcMngr.SelectMainNode()
 	wb.RssSendMinerSelect()
@@ -182,112 +216,45 @@ func (wb *EmulatorWeb) HandleMinerSelect(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleTick(ctx echo.Context) error {
-	if len(wb.RcMngr.Nodes) == 0 {
-		wb.RssLogErrorSend("No Nodes exists. Aborting TICK operation")
-		return nil
-	}
-	if wb.RcMngr.Tick == 0 {
-		wb.RssLogInfoSend("First tick: initiating GENESIS block")
-		return wb.HandleTickGenesis(ctx)
-	}
-	return wb.HandleTickGeneral(ctx)
+	return wb.Sched.Step()
 }
 
-func (wb *EmulatorWeb) HandleTickGenesis(ctx echo.Context) error {
-	logTitle := "Genesis Block: "
-	if wb.RcMngr.MainNode() == nil {
-		wb.RssLogInfoSend(logTitle + "no main node selected. Selecting Main node.")
-		wb.SelectMainNode()
-		time.Sleep(OP_PAUSE_MILISEC)
-	}
-	n := wb.RcMngr.MainNode()
-	wb.RssLogInfoSend(fmt.Sprintf("Node %s: Creating genesis block candidate", n.Name))
-	_, err := n.InitGenesisBlock()
-	if err != nil {
-		wb.RssLogErrorSend(logTitle + err.Error() + " : ABORTING")
-		return err
-	}
-
-	time.Sleep(OP_PAUSE_MILISEC)
-
-	wb.RssLogOKSend(logTitle + "genesis block candidate created")
-	wb.RssLogInfoSend(logTitle + "Start block mining")
-	t := time.Now()
-	b, err := wb.RcMngr.Mine()
-	d := time.Since(t)
-	if err != nil {
-		wb.RssLogErrorSend(fmt.Sprintf("%sMine fialed: %s", logTitle, err))
-		return err
-	}
-
-	wb.RcMngr.Tick++
-
-	wb.RssLogOKSend(logTitle+"Genesis block mined succesfully in %2.f seconds", d.Seconds())
-
-	wb.RssNodeAllUpdates(n.Id)
-
-	wb.RssLogInfoSend("Sending genesis block to other nodes")
-	for id, nn := range wb.RcMngr.Nodes {
-		if id != n.Id {
-			wb.RssLogInfoSend(fmt.Sprintf("Node [%s]: evaluate new block", nn.Name))
-			if err = nn.AddVerifyBlock(b); err != nil {
-				wb.RssLogErrorSend(err.Error())
-			}
-			wb.RssLogOKSend(fmt.Sprintf("Node [%s]: new block added", nn.Name))
-			wb.RssSendNodeLastBlock(nn.Id)
-			wb.RssSendNodeCoinbase(nn.Id)
-		}
-	}
-	wb.RssLogOKSend(logTitle + "Genesis block add succesfully")
+// Runs single emulation tick. Ticks from handlers and scheduler never overlap
+func (wb *EmulatorWeb) Tick() error {
+	wb.mu.Lock()
+	err := wb.RcMngr.RunTick(wb.Logger())
+	if err == nil {
+		wb.RssTick()
+	}
+	pause := wb.RcMngr.TakePause()
+	wb.mu.Unlock()
+	time.Sleep(pause)
+	return err
+}
 
-	time.Sleep(OP_PAUSE_MILISEC)
-	wb.SelectMainNode()
-	wb.RssTick()
+func (wb *EmulatorWeb) HandleSchedPlay(ctx echo.Context) error {
+	wb.RssLogInfoSend("Scheduler: play, %d ticks per minute", wb.Sched.Speed())
+	wb.Sched.Play()
 	return nil
 }
 
-func (wb *EmulatorWeb) HandleTickGeneral(ctx echo.Context) error {
-	logPrefix := fmt.Sprintf("New Tick (%d): ", wb.RcMngr.Tick+1)
-
-	ferr := func(msg string) error {
-		wb.RssLogErrorSend(msg)
-		return fmt.Errorf(logPrefix+"%s", msg)
-	}
-
-	wb.RssLogInfoSend(logPrefix + "Starting")
+func (wb *EmulatorWeb) HandleSchedPause(ctx echo.Context) error {
+	wb.RssLogInfoSend("Scheduler: pause")
+	wb.Sched.Pause()
+	return nil
+}
 
-	n := wb.RcMngr.MainNode()
-	if n == nil {
-		return ferr("No Miner node selected. Aborting new tick.")
-	}
-	wb.RssLogInfoSend(logPrefix+"Node [%s] start mining...", n.Name)
-	t := time.Now()
-	b, err := wb.RcMngr.Mine()
+func (wb *EmulatorWeb) HandleSchedSpeed(ctx echo.Context) error {
+	tpm, err := strconv.Atoi(ctx.FormValue("tpm"))
 	if err != nil {
-		return ferr(err.Error())
+		wb.RssLogErrorSend("Scheduler: speed is not integer")
+		return nil
 	}
-	wb.RssLogOKSend(logPrefix+"Node [%s] finished mining in %.2f seconds", n.Name, time.Since(t).Seconds())
-	wb.RssNodeAllUpdates(n.Id)
-	wb.RssLogInfoSend(logPrefix + "Sending block to other Nodes")
-
-	for _, nd := range wb.RcMngr.Nodes {
-		if nd.Id == n.Id {
-			continue
-		}
-		wb.RssLogInfoSend(logPrefix+"Node [%s] receiving new block...", nd.Name)
-		err = nd.AddVerifyBlock(b)
-		if err != nil {
-			wb.RssLogErrorSend(logPrefix+"Node [%s]: %s", nd.Name, err)
-			continue
-		}
-		wb.RssLogOKSend(logPrefix+"Node [%s]: block accepted", nd.Name)
-		wb.RssNodeAllUpdates(nd.Id)
+	if err = wb.Sched.SetSpeed(tpm); err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return nil
 	}
-
-	wb.RcMngr.Tick++
-	wb.RssTick()
-
-	wb.SelectMainNode()
+	wb.RssLogInfoSend("Scheduler: speed set to %d ticks per minute", tpm)
 	return nil
 }
 
@@ -301,68 +268,69 @@ func (wb *EmulatorWeb) HandleAddTransaction(ctx echo.Context) error {
 	wb.RssLogInfoSend(logTitle + "START")
 
 	widFrom := ctx.FormValue("WalletList")
-	widTo := ctx.FormValue("sendTo")
 
 	if widFrom == "" {
 		return ferr("From address is empty")
 	}
-	if widTo == "" {
-		return ferr("To address is empty")
-	}
-	if widTo == widFrom {
-		return ferr("From address and To address nust not be equal")
-	}
 
 	inpForm, err := ctx.FormParams()
 	if err != nil {
 		fmt.Println("Error Form Params\n", err)
 		return ferr("Server error")
 	}
+	to, amounts := inpForm["sendTo"], inpForm["amount"]
+	if len(to) != len(amounts) {
+		return ferr("Each recipient needs an amount")
+	}
 
-	delete(inpForm, "WalletList")
-	delete(inpForm, "sendTo")
-
-	inUtxo := make([]string, 0, len(inpForm))
-	outAmount := make([]int, 0, len(inpForm))
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 
-	for id := range inpForm {
-		am, err0 := strconv.Atoi(ctx.FormValue(id))
-		if err0 != nil {
-			return ferr(fmt.Sprintf("Input from [%s] is not integer", id))
+	w, ok := wb.RcMngr.Wallets[widFrom]
+	if !ok {
+		return ferr(fmt.Sprintf("Wallet [%s] does not exist", widFrom))
+	}
+	pays := []ruscoin.Payment{}
+	for i, addr := range to {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
 		}
-		if am < 0 {
-			return ferr(fmt.Sprintf("Input from [%s] is negative ", id))
+		if addr == widFrom {
+			return ferr("From address and To address nust not be equal")
 		}
-		if am == 0 {
-			continue
+		if _, ok = wb.RcMngr.Wallets[addr]; !ok {
+			if _, ok = wb.RcMngr.MultisigByAddr(addr); !ok {
+				return ferr(fmt.Sprintf("Wallet [%s] does not exist", addr))
+			}
+		}
+		am, err := strconv.Atoi(amounts[i])
+		if err != nil {
+			return ferr(fmt.Sprintf("Amount to [%s] is not integer", addr))
 		}
-		inUtxo = append(inUtxo, id)
-		outAmount = append(outAmount, am)
+		pays = append(pays, ruscoin.Payment{Addr: addr, Amount: am})
 	}
-
-	if len(inUtxo) == 0 {
-		return ferr("No amounts to spend given")
+	if len(pays) == 0 {
+		return ferr("To address is empty")
 	}
-
-	w, ok := wb.RcMngr.Wallets[widFrom]
-
-	if !ok {
-		return ferr(fmt.Sprintf("Wallet [%s] does not exist", widFrom))
+	if sel := ctx.FormValue("selection"); sel != "" {
+		if _, err = ruscoin.NewCoinSelector(sel); err != nil {
+			return ferr(err.Error())
+		}
+		w.CoinSelection = sel
 	}
-	if _, ok = wb.RcMngr.Wallets[widTo]; !ok {
-		return ferr(fmt.Sprintf("Wallet [%s] does not exist", widTo))
+	if v := ctx.FormValue("fee"); v != "" {
+		fee, err := strconv.Atoi(v)
+		if err != nil || fee < 0 {
+			return ferr("Fee must be non negative integer")
+		}
+		w.Fee = fee
 	}
 
-	t, err := w.NewTransaction(inUtxo, outAmount, widTo)
+	t, err := wb.RcMngr.SendPayments(w, pays, wb.Logger())
 	if err != nil {
 		return ferr(err.Error())
 	}
-
-	wb.RssLogInfoSend(logTitle + " Transaction ready. Sending to main node...")
-
-	if err = wb.RcMngr.MainNode().AddVerifyTransaction(*t); err != nil {
-		return ferr(err.Error())
-	}
+	wb.RssLogInfoSend(fmt.Sprintf("%sTransaction with %d inputs and %d outputs sent to the network", logTitle, len(t.InputUtxo), len(t.OutputUtxo)))
 
 	wb.RssLogOKSend("Transaction added succesfully")
 
@@ -374,6 +342,8 @@ func (wb *EmulatorWeb) HandleMine(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleNodeInfo(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	nid := ctx.FormValue("nodeId")
 	if nid == "" {
 		return renderTempl(ctx, views.ItemNotFound("Нода", "Нода не выбрана"))
@@ -409,175 +379,1109 @@ func (wb *EmulatorWeb) HandleNodeInfo(ctx echo.Context) error {
 		}
 	}
 
-	bl := make([]views.BlockInfoSmallItem, len(n.BlockChain))
-	for i, b := range n.BlockChain {
-		bl[i] = blockToItem(b)
-	}
-	if wb.RcMngr.MainNode() == n {
-		if wb.RcMngr.mainNode.BlockCandidate != nil {
-			cb := blockToItem(wb.RcMngr.MainNode().BlockCandidate)
-			cb.Hash = "Candidate"
-			cb.Height = "-1"
-			bl = append(bl, cb)
+	bl := make([]views.BlockInfoSmallItem, len(n.BlockChain))
+	for i, b := range n.BlockChain {
+		bl[i] = blockToItem(b)
+	}
+	if wb.RcMngr.MainNode() == n {
+		if wb.RcMngr.mainNode.BlockCandidate != nil {
+			cb := blockToItem(wb.RcMngr.MainNode().BlockCandidate)
+			cb.Hash = "Candidate"
+			cb.Height = "-1"
+			bl = append(bl, cb)
+		}
+	}
+	return renderTempl(ctx, views.NodeInfoFull(nf, bl, ul))
+}
+
+func (wb *EmulatorWeb) HandleNodeSelectList(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	l := make([]views.SelectListItem, len(wb.RcMngr.Nodes))
+	i := 0
+	for _, n := range wb.RcMngr.Nodes {
+		l[i] = views.SelectListItem{
+			Id:   n.Id,
+			Name: n.Name,
+		}
+		i++
+	}
+	slices.SortFunc(l, func(a, b views.SelectListItem) int {
+		if a.Name > b.Name {
+			return 1
+		} else if a.Name < b.Name {
+			return -1
+		}
+		return 0
+	})
+	return renderTempl(ctx, views.NodeSelectList(l))
+}
+
+func (wb *EmulatorWeb) HandleWalletPage(ctx echo.Context) error {
+	panic("WebServer: Handlers: HandleWalletPage not implemented")
+}
+
+func (wb *EmulatorWeb) HandleWalletHistory(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return renderTempl(ctx, views.ItemNotFound("Wallet", "Wallet is not selected"))
+	}
+	h, err := wb.RcMngr.WalletHistory(w)
+	if err != nil {
+		wb.RssLogErrorSend("Wallet history: %s", err)
+		return renderTempl(ctx, views.ItemNotFound("Wallet ["+w.Name+"]", err.Error()))
+	}
+	hl := make([]views.WalletHistoryItem, len(h))
+	for i, e := range h {
+		hl[i] = views.WalletHistoryItem{
+			Height:       e.Height,
+			Time:         e.Time.Format(time.DateTime),
+			Counterparty: wb.RcMngr.Counterparties(&e),
+			Received:     e.Received,
+			Sent:         e.Sent,
+			Fee:          e.Fee,
+			Balance:      e.Balance,
+		}
+	}
+	return renderTempl(ctx, views.WalletHistory(w.Name, wb.RcMngr.WalletNode(w).Name, w.Addr, hl))
+}
+
+func (wb *EmulatorWeb) HandleWalletHistoryCSV(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return ctx.String(404, "wallet not found")
+	}
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", w.Name+"-history.csv"))
+	return wb.RcMngr.WriteWalletHistoryCSV(res, w)
+}
+
+func (wb *EmulatorWeb) HandleWalletBlockTr(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wid := ctx.FormValue("WalletList")
+	bhIn := ctx.FormValue("BlockHeight")
+	if wid == "" {
+		wb.RssLogErrorSend("Empty wallet id")
+		return renderTempl(ctx, views.ItemNotFound("Wallet", "Wallet is not selected"))
+	}
+	if bhIn == "" {
+		wb.RssLogErrorSend("Embty block height")
+		return renderTempl(ctx, views.ItemNotFound("Block", "Block height is not not given"))
+	}
+	wb.RssLogInfoSend(fmt.Sprintf("Searching wallet %s transactions in block %s", wid, bhIn))
+	bh, err := strconv.Atoi(bhIn)
+	if err != nil {
+		wb.RssLogErrorSend("Block height value is invalid")
+		return renderTempl(ctx, views.ItemNotFound("Block", "Block height value is invalid"))
+	}
+	n := wb.RcMngr.GetSetMainNode()
+	if bh < 0 || bh >= len(n.BlockChain) {
+		wb.RssLogErrorSend("No Block with height " + bhIn)
+		return renderTempl(ctx, views.ItemNotFound("Block "+bhIn, "Block not found"))
+	}
+	if _, ok := wb.RcMngr.Wallets[wid]; !ok {
+		wb.RssLogErrorSend("Wallet [" + wid + "] not found")
+		return renderTempl(ctx, views.ItemNotFound("Wallet ["+wid+"]", "Wallet not found"))
+	}
+	b := n.BlockChain[bh]
+	trs := []views.WalletBlockTrItem{}
+	for _, t := range b.Body.Transactions {
+		inu, outu := t.FilterUtxoByWallet(wid)
+		if len(inu) == 0 && len(outu) == 0 {
+			continue
+		}
+		item := views.WalletBlockTrItem{
+			Sign:       t.SignString(),
+			Pk:         t.PkString(),
+			InputUtxo:  make([]string, len(inu)),
+			OutputUtxo: make([]string, len(outu)),
+		}
+		i := 0
+		for _, u := range inu {
+			item.InputUtxo[i] = strconv.Itoa(u.Amount)
+			i++
+		}
+		i = 0
+		for _, u := range outu {
+			item.OutputUtxo[i] = strconv.Itoa(u.Amount)
+			i++
+		}
+		trs = append(trs, item)
+	}
+	return renderTempl(
+		ctx,
+		views.WalletBlockInfo(b.Header.Time.Format("2006-01-02 15:04:05"), b.HashString(), trs),
+	)
+}
+
+func (wb *EmulatorWeb) HandleWalletList(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wid := ctx.FormValue("wid")
+	var wlist []views.SelectListItem
+	i := 0
+	if wid == "" {
+		wlist = make([]views.SelectListItem, len(wb.RcMngr.Wallets))
+		for _, w := range wb.RcMngr.Wallets {
+			wlist[i] = wb.walletToSelectListItems(w)
+			i++
+		}
+	} else {
+		wlist = []views.SelectListItem{}
+		for _, w := range wb.RcMngr.Wallets {
+			if strings.HasPrefix(w.Addr, wid) {
+				wlist = append(wlist, wb.walletToSelectListItems(w))
+			}
+		}
+	}
+	slices.SortFunc(wlist, func(a, b views.SelectListItem) int {
+		if a.Name > b.Name {
+			return 1
+		} else if a.Name < b.Name {
+			return -1
+		}
+		return 0
+	})
+	return renderTempl(ctx, views.WalletSelectList(wlist))
+}
+
+func (wb *EmulatorWeb) HandleWalletOptions(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wlist := make([]views.SelectListItem, 0, len(wb.RcMngr.Wallets))
+	for _, a := range wb.RcMngr.WalletAddrs() {
+		wlist = append(wlist, wb.walletToSelectListItems(wb.RcMngr.Wallets[a]))
+	}
+	slices.SortFunc(wlist, func(a, b views.SelectListItem) int { return strings.Compare(a.Name, b.Name) })
+	return renderTempl(ctx, views.WalletSelectOptions(wlist))
+}
+
+func (wb *EmulatorWeb) HandleEimulationSettings(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	s := views.EmulationSettingsItem{
+		CoinbaseStart:    strconv.Itoa(ruscoin.COINBASE_START_AMOUNT),
+		RewardAmount:     strconv.Itoa(ruscoin.REWARD_AMOUNT),
+		Diff:             ruscoin.MINE_DIFF,
+		Seed:             "random",
+		ChainId:          ruscoin.CHAIN_ID,
+		ReplayProtection: "выкл",
+		MaxBlockSize:     strconv.Itoa(ruscoin.MAX_BLOCK_SIZE),
+	}
+	if ruscoin.SEED != 0 {
+		s.Seed = strconv.FormatInt(ruscoin.SEED, 10)
+	}
+	if ruscoin.REPLAY_PROTECTION {
+		s.ReplayProtection = "вкл"
+	}
+	return renderTempl(ctx, views.EmulationSettings(s))
+}
+
+func (wb *EmulatorWeb) HandleWalletUtxoTable(ctx echo.Context) error {
+	wid := ctx.FormValue("WalletList")
+	if wid == "" {
+		return nil
+	}
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[wid]
+	if !ok {
+		fmt.Printf("ERROR: wallet not foun: %s", wid)
+		return nil
+	}
+
+	n := wb.RcMngr.WalletNode(w)
+	if vn, ok := wb.RcMngr.Nodes[ctx.FormValue("viewNode")]; ok {
+		n = vn
+	}
+	if n == nil {
+		return nil
+	}
+	v := wb.RcMngr.WalletView(w, n)
+	b := views.WalletBalanceItem{
+		Node:       n.Name,
+		Height:     v.Height,
+		Confirmed:  v.Confirmed,
+		Available:  v.Available(),
+		Immature:   v.Immature,
+		PendingIn:  v.PendingIn,
+		PendingOut: v.PendingOut,
+	}
+	ul := make([]views.WalletUtxoItem, len(v.Utxo))
+	for i, u := range v.Utxo {
+		ul[i] = views.WalletUtxoItem{
+			Id:       u.Id,
+			Amount:   u.Amount,
+			Depth:    u.Depth,
+			Immature: u.Immature(),
+			Spending: u.Spending,
+		}
+	}
+	return renderTempl(ctx, views.WalletUtxoTable(b, ul, wb.pendingToItems(w)))
+}
+
+func (wb *EmulatorWeb) pendingToItems(w *ruscoin.Wallet) []views.PendingTxItem {
+	n := wb.RcMngr.EntryNode(w)
+	res := []views.PendingTxItem{}
+	for _, t := range wb.RcMngr.PendingTransactions(w, true) {
+		p := views.PendingTxItem{
+			Sign:          t.SignString(),
+			Incoming:      len(w.OwnUtxo(t.InputUtxo)) == 0,
+			Fee:           t.Fee(),
+			FeeRate:       t.FeeRate(),
+			EffectiveRate: n.EffectiveFeeRate(&t),
+		}
+		for _, u := range t.OutputUtxo {
+			if w.Owns(u.Addr) == p.Incoming {
+				p.Amount += u.Amount
+			}
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+func (wb *EmulatorWeb) HandleWalletBump(ctx echo.Context) error {
+	logTitle := "Fee bump: "
+	ferr := func(msg string) error {
+		wb.RssLogErrorSend(logTitle + msg)
+		return renderTempl(ctx, views.WalletTrResult(false, msg))
+	}
+	wid := ctx.FormValue("WalletList")
+	if wid == "" {
+		return ferr("Wallet is not selected")
+	}
+	fee, err := strconv.Atoi(ctx.FormValue("bumpFee"))
+	if err != nil || fee < 1 {
+		return ferr("Fee must be positive integer")
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	w, ok := wb.RcMngr.Wallets[wid]
+	if !ok {
+		return ferr(fmt.Sprintf("Wallet [%s] does not exist", wid))
+	}
+	if _, err := wb.RcMngr.BumpFee(w, ctx.FormValue("mode"), fee, wb.Logger()); err != nil {
+		return ferr(err.Error())
+	}
+	return renderTempl(ctx, views.WalletTrResult(true, "Fee bumped succesfully"))
+}
+
+func (wb *EmulatorWeb) HandleWalletSelect(ctx echo.Context) error {
+	if ctx.Request().Method != "POST" {
+		return fmt.Errorf("Must be POST request")
+	}
+	panic("WebServer: Handlers: HandleWalletSelect: not implemented")
+}
+
+// Node and wallet management handlers
+
+func (wb *EmulatorWeb) HandleNodeNew(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	name := strings.TrimSpace(ctx.FormValue("name"))
+	if name == "" {
+		wb.RssLogErrorSend("New node: name is empty")
+		return nil
+	}
+	n, err := wb.RcMngr.NewNode(name)
+	if err != nil {
+		wb.RssLogErrorSend("New node: %s", err)
+		return nil
+	}
+	wb.RssLogOKSend("Node [%s] created", n.Name)
+	if added, err := wb.RcMngr.SyncNode(n.Id); err != nil {
+		wb.RssLogErrorSend("Node [%s]: chain sync failed: %s", n.Name, err)
+	} else if added > 0 {
+		wb.RssLogOKSend("Node [%s]: synced %d blocks", n.Name, added)
+	}
+	wb.RssNodeListChanged()
+	wb.RssWalletListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeRename(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	name := strings.TrimSpace(ctx.FormValue("name"))
+	if err := wb.RcMngr.RenameNode(id, name); err != nil {
+		wb.RssLogErrorSend("Rename node: %s", err)
+		return nil
+	}
+	wb.RssLogOKSend("Node [%s] renamed to %s", id, name)
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeCrash(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	wasMain := wb.RcMngr.MainNode() != nil && wb.RcMngr.MainNode().Id == id
+	if err := wb.RcMngr.CrashNode(id); err != nil {
+		wb.RssLogErrorSend("Crash node: %s", err)
+		return nil
+	}
+	wb.RssLogEvilSend("Node [%s] crashed", wb.RcMngr.Nodes[id].Name)
+	if wasMain {
+		wb.RssSendMinerSelect()
+	}
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeRestore(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	added, err := wb.RcMngr.RestoreNode(id)
+	if n, ok := wb.RcMngr.Nodes[id]; ok {
+		wb.RssLogOKSend("Node [%s] restored, synced %d blocks", n.Name, added)
+	}
+	if err != nil {
+		wb.RssLogErrorSend("Restore node: %s", err)
+	}
+	if wb.RcMngr.MainNode() == nil && wb.RcMngr.SelectMainNode() != nil {
+		wb.RssSendMinerSelect()
+	}
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeHashPower(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	power, err := strconv.Atoi(ctx.FormValue("power"))
+	if err != nil {
+		wb.RssLogErrorSend("Hash power: value is not integer")
+		return nil
+	}
+	if err = wb.RcMngr.SetHashPower(id, power); err != nil {
+		wb.RssLogErrorSend("Hash power: %s", err)
+		return nil
+	}
+	wb.RssLogOKSend("Node [%s]: hash power set to %d", wb.RcMngr.Nodes[id].Name, power)
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeClock(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	sec, err := strconv.Atoi(ctx.FormValue("skew"))
+	if err != nil {
+		wb.RssLogErrorSend("Clock skew: value is not integer")
+		return nil
+	}
+	if err = wb.RcMngr.SetClockSkew(id, time.Duration(sec)*time.Second); err != nil {
+		wb.RssLogErrorSend("Clock skew: %s", err)
+		return nil
+	}
+	wb.RssLogOKSend("Node [%s]: clock skew set to %ds", wb.RcMngr.Nodes[id].Name, sec)
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeBehaviour(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	b, err := wb.RcMngr.NewBehaviour(ctx.FormValue("kind"), ctx.FormValue("param"))
+	if err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return nil
+	}
+	if err = wb.RcMngr.SetBehaviour(id, b); err != nil {
+		wb.RssLogErrorSend("Behaviour: %s", err)
+		return nil
+	}
+	wb.RssLogOKSend("Node [%s]: behaviour set to %s", wb.RcMngr.Nodes[id].Name, b)
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleNodeDelete(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	id := ctx.FormValue("nodeId")
+	n, err := wb.RcMngr.GetNode(id)
+	if err != nil {
+		wb.RssLogErrorSend("Delete node: %s", err)
+		return nil
+	}
+	wasMain := wb.RcMngr.MainNode() == n
+	if err = wb.RcMngr.RemoveNode(id); err != nil {
+		wb.RssLogErrorSend("Delete node: %s", err)
+		return nil
+	}
+	wb.RssLogOKSend("Node [%s] deleted", n.Name)
+	if wasMain {
+		wb.RssSendMinerSelect()
+	}
+	wb.RssNodeListChanged()
+	return nil
+}
+
+func (wb *EmulatorWeb) HandleWalletNew(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	name := strings.TrimSpace(ctx.FormValue("name"))
+	if name == "" {
+		wb.RssLogErrorSend("New wallet: name is empty")
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet name is empty"))
+	}
+	var w *ruscoin.Wallet
+	var err error
+	if ctx.FormValue("hd") != "" {
+		w, err = wb.RcMngr.NewHDWallet(name, "")
+	} else {
+		w, err = wb.RcMngr.NewWallet(name)
+	}
+	if err != nil {
+		wb.RssLogErrorSend("New wallet: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogOKSend("Wallet [%s] created: %s", w.Name, w.Addr)
+	wb.RssWalletListChanged()
+	if w.HD() {
+		return renderTempl(ctx, views.WalletSeedPhrase(w.Name, w.Mnemonic()))
+	}
+	return renderTempl(ctx, views.WalletTrResult(true, "Wallet "+w.Name+" created"))
+}
+
+func (wb *EmulatorWeb) HandleWalletRestore(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	name := strings.TrimSpace(ctx.FormValue("name"))
+	if name == "" {
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet name is empty"))
+	}
+	w, err := wb.RcMngr.NewHDWallet(name, ctx.FormValue("mnemonic"))
+	if err != nil {
+		wb.RssLogErrorSend("Restore wallet: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogOKSend("Wallet [%s] restored from seed phrase: balance %d", w.Name, w.Balance())
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, fmt.Sprintf("Wallet %s restored, balance %d", w.Name, w.Balance())))
+}
+
+func (wb *EmulatorWeb) HandleWalletAddrs(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet is not selected"))
+	}
+	amounts := map[string]int{}
+	for _, u := range w.Utxo {
+		amounts[u.Addr] += u.Amount
+	}
+	al := []views.WalletAddrItem{}
+	for _, a := range w.Addrs() {
+		al = append(al, views.WalletAddrItem{
+			Addr:   a.Addr,
+			Change: a.Branch == ruscoin.HD_CHANGE,
+			Index:  a.Index,
+			Used:   a.Used,
+			Amount: amounts[a.Addr],
+		})
+	}
+	return renderTempl(ctx, views.WalletAddrs(w.Name, w.ReceiveAddr(), al))
+}
+
+func (wb *EmulatorWeb) HandleWalletExport(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet is not selected"))
+	}
+	ks, err := w.ExportKey(ctx.FormValue("passphrase"))
+	if err != nil {
+		wb.RssLogErrorSend("Export key: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogOKSend("Wallet [%s]: key exported", w.Name)
+	return renderTempl(ctx, views.WalletKeyExport(w.Name, string(ks)))
+}
+
+// Keystore JSON from uploaded file or, if no file is given, from the text field
+func keystoreFormValue(ctx echo.Context) ([]byte, error) {
+	fh, err := ctx.FormFile("keyfile")
+	if err != nil || fh.Size == 0 {
+		return []byte(ctx.FormValue("keystore")), nil
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open key file: %s", err)
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, 1<<16))
+}
+
+func (wb *EmulatorWeb) HandleWalletImport(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	ks, err := keystoreFormValue(ctx)
+	if err == nil && len(strings.TrimSpace(string(ks))) == 0 {
+		err = fmt.Errorf("Keystore is empty")
+	}
+	if err != nil {
+		wb.RssLogErrorSend("Import wallet: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	w, err := wb.RcMngr.ImportWallet(strings.TrimSpace(ctx.FormValue("name")), ks, ctx.FormValue("passphrase"))
+	if err != nil {
+		wb.RssLogErrorSend("Import wallet: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogOKSend("Wallet [%s] imported: %s", w.Name, w.Addr)
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, "Wallet "+w.Name+" imported"))
+}
+
+func (wb *EmulatorWeb) HandleWalletRename(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wid := ctx.FormValue("WalletList")
+	name := strings.TrimSpace(ctx.FormValue("name"))
+	if err := wb.RcMngr.RenameWallet(wid, name); err != nil {
+		wb.RssLogErrorSend("Rename wallet: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogOKSend("Wallet [%s] renamed to %s", wid, name)
+	wb.RssWalletListChanged()
+	wb.RssNodeListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, "Wallet renamed"))
+}
+
+func (wb *EmulatorWeb) HandleWalletOffline(ctx echo.Context) error {
+	return wb.walletSetOffline(ctx, true)
+}
+
+func (wb *EmulatorWeb) HandleWalletOnline(ctx echo.Context) error {
+	return wb.walletSetOffline(ctx, false)
+}
+
+func (wb *EmulatorWeb) walletSetOffline(ctx echo.Context, offline bool) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wid := ctx.FormValue("WalletList")
+	if err := wb.RcMngr.SetWalletOffline(wid, offline); err != nil {
+		wb.RssLogErrorSend("Wallet status: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	msg := "Wallet is online"
+	if offline {
+		msg = "Wallet is offline"
+	}
+	wb.RssLogOKSend("Wallet [%s]: %s", wid, msg)
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, msg))
+}
+
+func (wb *EmulatorWeb) HandleWalletAttach(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet is not selected"))
+	}
+	msg := "Wallet follows the public chain"
+	var n *ruscoin.Node
+	if nid := ctx.FormValue("walletNode"); ctx.FormValue("public") == "" {
+		if nid == "" {
+			return renderTempl(ctx, views.WalletTrResult(false, "Node is not selected"))
+		}
+		if n, ok = wb.RcMngr.Nodes[nid]; !ok {
+			return renderTempl(ctx, views.WalletTrResult(false, fmt.Sprintf("Node [%s] does not exist", nid)))
+		}
+		msg = "Wallet attached to " + n.Name
+	}
+	wb.RcMngr.AttachWallet(w, n)
+	wb.RssLogOKSend("Wallet [%s]: %s", w.Name, msg)
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, msg))
+}
+
+func (wb *EmulatorWeb) HandleWalletRescan(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet is not selected"))
+	}
+	if err := wb.RcMngr.RescanWallet(w); err != nil {
+		wb.RssLogErrorSend("Wallet rescan: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	msg := fmt.Sprintf("Wallet rescanned %d blocks, balance %d", w.SyncHeight()+1, w.Balance())
+	wb.RssLogOKSend("Wallet [%s]: %s", w.Name, msg)
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, msg))
+}
+
+func (wb *EmulatorWeb) multisigToItems() []views.MultisigItem {
+	res := []views.MultisigItem{}
+	for _, a := range wb.RcMngr.MultisigList() {
+		item := views.MultisigItem{
+			Name:    a.Name,
+			Addr:    a.Addr(),
+			Scheme:  a.Ms.String(),
+			Owners:  a.Owners,
+			Balance: wb.RcMngr.MultisigUtxo(a).Sum(),
+			Signed:  a.Signed,
+		}
+		if a.Pending != nil {
+			for _, u := range a.Pending.OutputUtxo {
+				item.Pending = append(item.Pending, fmt.Sprintf("%d -> %s", u.Amount, wb.RcMngr.walletLabel(u.Addr)))
+			}
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func (wb *EmulatorWeb) HandleMultisigTable(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.MultisigTable(wb.multisigToItems(), ctx.QueryParam("MultisigList")))
+}
+
+func (wb *EmulatorWeb) HandleMultisigNew(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	m, err := strconv.Atoi(ctx.FormValue("m"))
+	if err != nil {
+		return renderTempl(ctx, views.WalletTrResult(false, "Required signs is not integer"))
+	}
+	owners := []*ruscoin.Wallet{}
+	for _, name := range strings.Split(ctx.FormValue("owners"), ",") {
+		w, err := wb.RcMngr.WalletByName(strings.TrimSpace(name))
+		if err != nil {
+			return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+		}
+		owners = append(owners, w)
+	}
+	a, err := wb.RcMngr.NewMultisig(strings.TrimSpace(ctx.FormValue("name")), m, owners)
+	if err != nil {
+		wb.RssLogErrorSend("New multisig: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogOKSend("Multisig [%s] %s of %s created", a.Name, a.Ms, strings.Join(a.Owners, ", "))
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, fmt.Sprintf("Multisig %s %s created", a.Name, a.Ms)))
+}
+
+func (wb *EmulatorWeb) HandleMultisigSpend(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	a, err := wb.RcMngr.MultisigByName(ctx.FormValue("MultisigList"))
+	if err != nil {
+		return renderTempl(ctx, views.WalletTrResult(false, "Multisig is not selected"))
+	}
+	to := strings.TrimSpace(ctx.FormValue("sendTo"))
+	if _, ok := wb.RcMngr.Wallets[to]; !ok {
+		if _, ok = wb.RcMngr.MultisigByAddr(to); !ok {
+			return renderTempl(ctx, views.WalletTrResult(false, fmt.Sprintf("Wallet [%s] does not exist", to)))
+		}
+	}
+	amount, err := strconv.Atoi(ctx.FormValue("amount"))
+	if err != nil {
+		return renderTempl(ctx, views.WalletTrResult(false, "Amount is not integer"))
+	}
+	fee := 0
+	if v := ctx.FormValue("fee"); v != "" {
+		if fee, err = strconv.Atoi(v); err != nil || fee < 0 {
+			return renderTempl(ctx, views.WalletTrResult(false, "Fee must be non negative integer"))
+		}
+	}
+	if _, err = wb.RcMngr.ProposeMultisigSpend(a, to, amount, fee); err != nil {
+		wb.RssLogErrorSend("Multisig spend: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssLogInfoSend("Multisig [%s]: spend of %d proposed, waits for %d signs", a.Name, amount, a.Ms.M)
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, fmt.Sprintf("Spend proposed, %d signs required", a.Ms.M)))
+}
+
+func (wb *EmulatorWeb) HandleMultisigSign(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	a, err := wb.RcMngr.MultisigByName(ctx.FormValue("MultisigList"))
+	if err != nil {
+		return renderTempl(ctx, views.WalletTrResult(false, "Multisig is not selected"))
+	}
+	w, ok := wb.RcMngr.Wallets[ctx.FormValue("WalletList")]
+	if !ok {
+		return renderTempl(ctx, views.WalletTrResult(false, "Wallet is not selected"))
+	}
+	sent, err := wb.RcMngr.SignMultisig(a, w, wb.Logger())
+	if err != nil {
+		wb.RssLogErrorSend("Multisig sign: %s", err)
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssWalletListChanged()
+	if sent {
+		return renderTempl(ctx, views.WalletTrResult(true, "Spend has enough signs and is sent"))
+	}
+	return renderTempl(ctx, views.WalletTrResult(true, fmt.Sprintf("%s signed, %d of %d signs", w.Name, len(a.Signed), a.Ms.M)))
+}
+
+func (wb *EmulatorWeb) HandleMultisigSubmit(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	a, err := wb.RcMngr.MultisigByName(ctx.FormValue("MultisigList"))
+	if err != nil {
+		return renderTempl(ctx, views.WalletTrResult(false, "Multisig is not selected"))
+	}
+	if err := wb.RcMngr.SubmitMultisig(a, wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.WalletTrResult(false, err.Error()))
+	}
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.WalletTrResult(true, "Spend sent"))
+}
+
+// END Node and wallet management handlers
+
+// Attack handlers
+
+func (wb *EmulatorWeb) HandleSelfishStart(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	gamma, err := strconv.ParseFloat(ctx.FormValue("gamma"), 64)
+	if err != nil {
+		wb.RssLogErrorSend("Selfish: gamma is not a number")
+		return renderTempl(ctx, views.SelfishStatus(wb.selfishToItem()))
+	}
+	s, err := wb.RcMngr.StartSelfishMining(ctx.FormValue("nodeId"), gamma)
+	if err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.SelfishStatus(wb.selfishToItem()))
+	}
+	wb.RssLogEvilSend("Selfish: node [%s] starts selfish mining, gamma %.2f", s.Node.Name, gamma)
+	wb.RssNodeListChanged()
+	return renderTempl(ctx, views.SelfishStatus(wb.selfishToItem()))
+}
+
+func (wb *EmulatorWeb) HandleSelfishStop(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if err := wb.RcMngr.StopSelfishMining(wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+	} else {
+		wb.RssLogOKSend("Selfish: attack stopped")
+		wb.RssNodeListChanged()
+		wb.RssWalletListChanged()
+	}
+	return renderTempl(ctx, views.SelfishStatus(wb.selfishToItem()))
+}
+
+func (wb *EmulatorWeb) HandleSelfishStatus(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.SelfishStatus(wb.selfishToItem()))
+}
+
+func (wb *EmulatorWeb) HandleDoubleSpendStart(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	amount, err1 := strconv.Atoi(ctx.FormValue("amount"))
+	conf, err2 := strconv.Atoi(ctx.FormValue("confirmations"))
+	if err1 != nil || err2 != nil {
+		wb.RssLogErrorSend("DoubleSpend: amount and confirmations must be numbers")
+		return renderTempl(ctx, views.DoubleSpendStatus(wb.doubleSpendToItem()))
+	}
+	if _, err := wb.RcMngr.StartDoubleSpend(ctx.FormValue("nodeId"), ctx.FormValue("victim"), amount, conf, wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.DoubleSpendStatus(wb.doubleSpendToItem()))
+	}
+	wb.RssNodeListChanged()
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.DoubleSpendStatus(wb.doubleSpendToItem()))
+}
+
+func (wb *EmulatorWeb) HandleDoubleSpendStop(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if err := wb.RcMngr.StopDoubleSpend(wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+	} else {
+		wb.RssLogOKSend("DoubleSpend: attack aborted")
+		wb.RssNodeListChanged()
+		wb.RssWalletListChanged()
+	}
+	return renderTempl(ctx, views.DoubleSpendStatus(wb.doubleSpendToItem()))
+}
+
+func (wb *EmulatorWeb) HandleDoubleSpendStatus(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.DoubleSpendStatus(wb.doubleSpendToItem()))
+}
+
+func (wb *EmulatorWeb) HandleSybilStart(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	form, err := ctx.FormParams()
+	if err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.SybilStatus(wb.sybilToItem()))
+	}
+	count, err1 := strconv.Atoi(ctx.FormValue("count"))
+	per, err2 := strconv.Atoi(ctx.FormValue("perVictim"))
+	delay, err3 := strconv.Atoi(ctx.FormValue("delay"))
+	if err1 != nil || err2 != nil || err3 != nil {
+		wb.RssLogErrorSend("Sybil: nodes, links and delay must be numbers")
+		return renderTempl(ctx, views.SybilStatus(wb.sybilToItem()))
+	}
+	c := SybilConfig{
+		Owner:     strings.TrimSpace(ctx.FormValue("owner")),
+		Count:     count,
+		PerVictim: per,
+		Victims:   form["victim"],
+		Exclusive: ctx.FormValue("exclusive") != "",
+		Mode:      ctx.FormValue("mode"),
+		Delay:     delay,
+		Targets:   form["target"],
+	}
+	if _, err := wb.RcMngr.StartSybil(c, wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.SybilStatus(wb.sybilToItem()))
+	}
+	wb.RssNodeListChanged()
+	return renderTempl(ctx, views.SybilStatus(wb.sybilToItem()))
+}
+
+func (wb *EmulatorWeb) HandleSybilStop(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if err := wb.RcMngr.StopSybil(wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+	} else {
+		wb.RssNodeListChanged()
+	}
+	return renderTempl(ctx, views.SybilStatus(wb.sybilToItem()))
+}
+
+func (wb *EmulatorWeb) HandleSybilStatus(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.SybilStatus(wb.sybilToItem()))
+}
+
+// END Attack handlers
+
+// Network handlers
+
+func (wb *EmulatorWeb) HandleNetworkStatus(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.NetworkStatus(wb.networkToItem()))
+}
+
+// Checkbox list of nodes, checkbox name is taken from query
+func (wb *EmulatorWeb) HandleNetworkChecks(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	l := make([]views.SelectListItem, 0, len(wb.RcMngr.Nodes))
+	for _, id := range wb.RcMngr.NodeIds() {
+		n := wb.RcMngr.Nodes[id]
+		l = append(l, views.SelectListItem{Id: n.Id, Name: n.Name})
+	}
+	slices.SortFunc(l, func(a, b views.SelectListItem) int { return strings.Compare(a.Name, b.Name) })
+	return renderTempl(ctx, views.NetworkNodeChecks(ctx.QueryParam("name"), l))
+}
+
+func (wb *EmulatorWeb) HandleNetworkLink(ctx echo.Context) error {
+	return wb.networkChange(ctx, "link", func() error {
+		return wb.RcMngr.Link(ctx.FormValue("nodeA"), ctx.FormValue("nodeB"))
+	})
+}
+
+func (wb *EmulatorWeb) HandleNetworkUnlink(ctx echo.Context) error {
+	return wb.networkChange(ctx, "unlink", func() error {
+		return wb.RcMngr.Unlink(ctx.FormValue("nodeA"), ctx.FormValue("nodeB"))
+	})
+}
+
+func (wb *EmulatorWeb) HandleNetworkCut(ctx echo.Context) error {
+	return wb.networkChange(ctx, "cut", func() error {
+		ticks, err := strconv.Atoi(ctx.FormValue("ticks"))
+		if err != nil {
+			return fmt.Errorf("ticks is not integer")
+		}
+		return wb.RcMngr.CutLink(ctx.FormValue("nodeA"), ctx.FormValue("nodeB"), ticks)
+	})
+}
+
+func (wb *EmulatorWeb) HandleNetworkSplit(ctx echo.Context) error {
+	return wb.networkChange(ctx, "split", func() error {
+		ticks, err := strconv.Atoi(ctx.FormValue("ticks"))
+		if err != nil {
+			return fmt.Errorf("ticks is not integer")
 		}
-	}
-	return renderTempl(ctx, views.NodeInfoFull(nf, bl, ul))
+		form, err := ctx.FormParams()
+		if err != nil {
+			return err
+		}
+		return wb.RcMngr.SplitNetwork([][]string{form["group"]}, ticks)
+	})
 }
 
-func (wb *EmulatorWeb) HandleNodeSelectList(ctx echo.Context) error {
-	l := make([]views.SelectListItem, len(wb.RcMngr.Nodes))
-	i := 0
-	for _, n := range wb.RcMngr.Nodes {
-		l[i] = views.SelectListItem{
-			Id:   n.Id,
-			Name: n.Name,
+func (wb *EmulatorWeb) HandleNetworkEclipse(ctx echo.Context) error {
+	return wb.networkChange(ctx, "eclipse", func() error {
+		ticks, err := strconv.Atoi(ctx.FormValue("ticks"))
+		if err != nil {
+			return fmt.Errorf("ticks is not integer")
 		}
-		i++
-	}
-	slices.SortFunc(l, func(a, b views.SelectListItem) int {
-		if a.Name > b.Name {
-			return 1
-		} else if a.Name < b.Name {
-			return -1
+		form, err := ctx.FormParams()
+		if err != nil {
+			return err
 		}
-		return 0
+		return wb.RcMngr.StartEclipse(ctx.FormValue("victim"), form["attacker"], ticks)
 	})
-	return renderTempl(ctx, views.NodeSelectList(l))
 }
 
-func (wb *EmulatorWeb) HandleWalletPage(ctx echo.Context) error {
-	panic("WebServer: Handlers: HandleWalletPage not implemented")
+func (wb *EmulatorWeb) HandleNetworkHeal(ctx echo.Context) error {
+	return wb.networkChange(ctx, "heal", func() error {
+		wb.RcMngr.HealNetwork(wb.Logger())
+		wb.RssWalletListChanged()
+		return nil
+	})
 }
 
-func (wb *EmulatorWeb) HandleWalletBlockTr(ctx echo.Context) error {
-	wid := ctx.FormValue("WalletList")
-	bhIn := ctx.FormValue("BlockHeight")
-	if wid == "" {
-		wb.RssLogErrorSend("Empty wallet id")
-		return renderTempl(ctx, views.ItemNotFound("Wallet", "Wallet is not selected"))
-	}
-	if bhIn == "" {
-		wb.RssLogErrorSend("Embty block height")
-		return renderTempl(ctx, views.ItemNotFound("Block", "Block height is not not given"))
+// Applies network change and notifies clients
+func (wb *EmulatorWeb) networkChange(ctx echo.Context, op string, f func() error) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if err := f(); err != nil {
+		wb.RssLogErrorSend("Network %s: %s", op, err)
+		return nil
 	}
-	wb.RssLogInfoSend(fmt.Sprintf("Searching wallet %s transactions in block %s", wid, bhIn))
-	bh, err := strconv.Atoi(bhIn)
+	wb.RssLogOKSend("Network: %s done", op)
+	wb.RssNodeListChanged()
+	return nil
+}
+
+// END Network handlers
+
+// Scenario handlers
+
+func (wb *EmulatorWeb) HandleScenarioTab(ctx echo.Context) error {
+	files, err := ScenarioFiles(SCENARIO_DIR)
 	if err != nil {
-		wb.RssLogErrorSend("Block height value is invalid")
-		return renderTempl(ctx, views.ItemNotFound("Block", "Block height value is invalid"))
+		wb.RssLogErrorSend("Scenario: %s", err)
 	}
-	n := wb.RcMngr.GetSetMainNode()
-	if bh < 0 || bh >= len(n.BlockChain) {
-		wb.RssLogErrorSend("No Block with height " + bhIn)
-		return renderTempl(ctx, views.ItemNotFound("Block "+bhIn, "Block not found"))
+	return renderTempl(ctx, views.TabScenario(files))
+}
+
+func (wb *EmulatorWeb) HandleScenarioStatus(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.ScenarioStatus(wb.scenarioToItem(), ""))
+}
+
+// Loads scenario from SCENARIO_DIR file or from posted text and replaces current emulation
+func (wb *EmulatorWeb) HandleScenarioLoad(ctx echo.Context) error {
+	var sc *Scenario
+	var err error
+	if text := strings.TrimSpace(ctx.FormValue("text")); text != "" {
+		sc, err = ParseScenario([]byte(text))
+	} else {
+		f := filepath.Base(ctx.FormValue("file"))
+		sc, err = LoadScenario(filepath.Join(SCENARIO_DIR, f))
 	}
-	if _, ok := wb.RcMngr.Wallets[wid]; !ok {
-		wb.RssLogErrorSend("Wallet [" + wid + "] not found")
-		return renderTempl(ctx, views.ItemNotFound("Wallet ["+wid+"]", "Wallet not found"))
+	if err == nil {
+		wb.Sched.Pause()
 	}
-	b := n.BlockChain[bh]
-	trs := []views.WalletBlockTrItem{}
-	for _, t := range b.Body.Transactions {
-		inu, outu := t.FilterUtxoByWallet(wid)
-		if len(inu) == 0 && len(outu) == 0 {
-			continue
-		}
-		item := views.WalletBlockTrItem{
-			Sign:       t.SignString(),
-			Pk:         t.PkString(),
-			InputUtxo:  make([]string, len(inu)),
-			OutputUtxo: make([]string, len(outu)),
-		}
-		i := 0
-		for _, u := range inu {
-			item.InputUtxo[i] = strconv.Itoa(u.Amount)
-			i++
-		}
-		i = 0
-		for _, u := range outu {
-			item.OutputUtxo[i] = strconv.Itoa(u.Amount)
-			i++
-		}
-		trs = append(trs, item)
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if err == nil {
+		err = wb.loadScenario(sc)
 	}
-	return renderTempl(
-		ctx,
-		views.WalletBlockInfo(b.Header.Time.Format("2006-01-02 15:04:05"), b.HashString(), trs),
-	)
+	if err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.ScenarioStatus(wb.scenarioToItem(), err.Error()))
+	}
+	wb.RssLogOKSend("Scenario [%s] loaded: %d steps", sc.Name, len(sc.Steps))
+	wb.RssTick()
+	wb.RssNodeListChanged()
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.ScenarioStatus(wb.scenarioToItem(), ""))
 }
 
-func (wb *EmulatorWeb) HandleWalletList(ctx echo.Context) error {
-	wid := ctx.FormValue("wid")
-	var wlist []views.SelectListItem
-	i := 0
-	if wid == "" {
-		wlist = make([]views.SelectListItem, len(wb.RcMngr.Wallets))
-		for _, w := range wb.RcMngr.Wallets {
-			wlist[i] = walletToSelectListItems(w)
-			i++
-		}
-	} else {
-		wlist = []views.SelectListItem{}
-		for _, w := range wb.RcMngr.Wallets {
-			if strings.HasPrefix(w.Addr, wid) {
-				wlist = append(wlist, walletToSelectListItems(w))
-			}
-		}
-	}
-	slices.SortFunc(wlist, func(a, b views.SelectListItem) int {
-		if a.Name > b.Name {
-			return 1
-		} else if a.Name < b.Name {
-			return -1
-		}
-		return 0
-	})
-	return renderTempl(ctx, views.WalletSelectList(wlist))
+func (wb *EmulatorWeb) HandleScenarioStep(ctx echo.Context) error {
+	return wb.scenarioRun(ctx, false)
 }
 
-func (wb *EmulatorWeb) HandleEimulationSettings(ctx echo.Context) error {
-	s := views.EmulationSettingsItem{
-		CoinbaseStart: strconv.Itoa(ruscoin.COINBASE_START_AMOUNT),
-		RewardAmount:  strconv.Itoa(ruscoin.REWARD_AMOUNT),
-		Diff:          ruscoin.MINE_DIFF,
-	}
-	return renderTempl(ctx, views.EmulationSettings(s))
+func (wb *EmulatorWeb) HandleScenarioRun(ctx echo.Context) error {
+	return wb.scenarioRun(ctx, true)
 }
 
-func (wb *EmulatorWeb) HandleWalletUtxoTable(ctx echo.Context) error {
-	wid := ctx.FormValue("WalletList")
-	if wid == "" {
-		return nil
+func (wb *EmulatorWeb) scenarioRun(ctx echo.Context, all bool) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if wb.Scenario == nil {
+		return renderTempl(ctx, views.ScenarioStatus(wb.scenarioToItem(), "Scenario is not loaded"))
 	}
-	w, ok := wb.RcMngr.Wallets[wid]
-	if !ok {
-		fmt.Printf("ERROR: wallet not foun: %s", wid)
-		return nil
+	if wb.Scenario.Done() {
+		return renderTempl(ctx, views.ScenarioStatus(wb.scenarioToItem(), "Scenario is finished"))
 	}
-	ul := make([]views.UtxoItem, len(w.Utxo))
-	i := 0
-	for id, u := range w.Utxo {
-		ul[i] = views.UtxoItem{
-			Id:     id,
-			Amount: strconv.Itoa(u.Amount),
-			Addr:   u.Addr,
-		}
-		i++
+	if all {
+		wb.Scenario.RunAll(wb.Logger())
+	} else {
+		wb.Scenario.Step(wb.Logger())
 	}
-
-	return renderTempl(ctx, views.WalletUtxoTable(ul))
+	// scenario steps run back to back, pauses of their ticks are dropped
+	wb.RcMngr.TakePause()
+	wb.RssTick()
+	wb.RssNodeListChanged()
+	wb.RssWalletListChanged()
+	return renderTempl(ctx, views.ScenarioStatus(wb.scenarioToItem(), ""))
 }
 
-func (wb *EmulatorWeb) HandleWalletSelect(ctx echo.Context) error {
-	if ctx.Request().Method != "POST" {
-		return fmt.Errorf("Must be POST request")
+// Replaces current emulation by the scenario one. Settings of the previous scenario are
+// restored first, so that they do not leak into the new one
+func (wb *EmulatorWeb) loadScenario(sc *Scenario) error {
+	if wb.Scenario != nil {
+		wb.Scenario.Close()
+		wb.Scenario = nil
 	}
-	panic("WebServer: Handlers: HandleWalletSelect: not implemented")
+	r, err := NewScenarioRunner(sc)
+	if err != nil {
+		return err
+	}
+	wb.Scenario = r
+	wb.RcMngr = r.Rm.WithOpPause(OP_PAUSE_MILISEC)
+	return nil
 }
 
+// END Scenario handlers
+
 // Evil Handlers
 
 func (wb *EmulatorWeb) HandleEvilLoad(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.evilLoad(ctx)
+}
+
+// Renders evil block, caller holds wb.mu
+func (wb *EmulatorWeb) evilLoad(ctx echo.Context) error {
 	if wb.RcMngr.EvilBlock == nil {
 		return renderTempl(ctx, views.ItemNotFound("Block", "No current evil block is set. Steal the block first"))
 	}
@@ -587,22 +1491,22 @@ func (wb *EmulatorWeb) HandleEvilLoad(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSteal(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Stealing block candidate")
-	n := wb.RcMngr.MainNode()
-	if n == nil {
-		return renderTempl(ctx, views.ItemNotFound("Main Node", "main node not set"))
-	}
-	b := n.BlockCandidate
-	if b == nil {
-		return renderTempl(ctx, views.ItemNotFound("Block", "block candidate not set"))
+	b, err := wb.RcMngr.EvilSteal()
+	if err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.ItemNotFound("Block", err.Error()))
 	}
-	wb.RcMngr.EvilBlock = b.Clone()
-	bItem := blockToItem(wb.RcMngr.EvilBlock)
+	bItem := blockToItem(b)
 	trItems := blockTransationToItems(b)
 	return renderTempl(ctx, views.EvilBlock(bItem, trItems))
 }
 
 func (wb *EmulatorWeb) HandleEvilSetHeihgt(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Setting new height")
 
 	if wb.RcMngr.EvilBlock == nil {
@@ -624,6 +1528,8 @@ func (wb *EmulatorWeb) HandleEvilSetHeihgt(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSetTime(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Setting new time")
 
 	if wb.RcMngr.EvilBlock == nil {
@@ -648,6 +1554,8 @@ func (wb *EmulatorWeb) HandleEvilSetTime(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSetHash(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Setting header value...")
 	if wb.RcMngr.EvilBlock == nil {
 		return wb.evilBlockSetFail(ctx, "Evil: no evil block set")
@@ -682,6 +1590,8 @@ func (wb *EmulatorWeb) HandleEvilSetHash(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSetNonce(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Setting nonce")
 	if wb.RcMngr.EvilBlock == nil {
 		return wb.evilBlockSetFail(ctx, "Evil: no evil block set")
@@ -698,6 +1608,8 @@ func (wb *EmulatorWeb) HandleEvilSetNonce(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSetInt(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	if wb.RcMngr.EvilBlock == nil {
 		return wb.evilBlockSetFail(ctx, "Evil: no evil block set")
 	}
@@ -727,6 +1639,8 @@ func (wb *EmulatorWeb) HandleEvilSetInt(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSetTrHashValue(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	fTid := ctx.FormValue("tid")
 
 	t, err := wb.evilGetTransaction(fTid)
@@ -760,6 +1674,8 @@ func (wb *EmulatorWeb) HandleEvilSetTrHashValue(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilSetTrUtxo(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	fTid := ctx.FormValue("tid")
 	t, err := wb.evilGetTransaction(fTid)
 	if err != nil {
@@ -802,6 +1718,8 @@ func (wb *EmulatorWeb) HandleEvilSetTrUtxo(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilDelUtxo(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Deleting utxo")
 	fTid := ctx.FormValue("tid")
 	t, err := wb.evilGetTransaction(fTid)
@@ -832,6 +1750,8 @@ func (wb *EmulatorWeb) HandleEvilDelUtxo(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilDelTr(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Removing transaction")
 	fTid := ctx.FormValue("tid")
 	tid, err := strconv.Atoi(fTid)
@@ -848,6 +1768,8 @@ func (wb *EmulatorWeb) HandleEvilDelTr(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilAddUtxo(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Adding Utxo")
 
 	tid := ctx.FormValue("tid")
@@ -891,6 +1813,8 @@ func (wb *EmulatorWeb) HandleEvilAddUtxo(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilAddTr(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Adding new transaction")
 	if wb.RcMngr.EvilBlock == nil {
 		wb.RssLogErrorSend("Evil: no evil block. Steal the block first.")
@@ -912,61 +1836,104 @@ func (wb *EmulatorWeb) HandleEvilAddTr(ctx echo.Context) error {
 }
 
 func (wb *EmulatorWeb) HandleEvilMine(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Start mining evil block")
-	if wb.RcMngr.EvilBlock == nil {
-		wb.RssLogErrorSend("Evil: evil block not set. Steal block first.")
-		return ctx.String(400, "Evil: evil block not set. Steal block first.")
-	}
-	n := wb.RcMngr.MainNode()
-	if n == nil {
-		wb.RssLogErrorSend("Evil: no main node set")
-		return ctx.String(400, "Evil: no main node set")
-	}
-	n.BlockCandidate = wb.RcMngr.EvilBlock
 	t := time.Now()
-	b, err := n.MineUnsafe()
-	if err != nil {
-		wb.RssLogErrorSend("Evil: Failed to mine block")
-		return ctx.String(400, "Evil: Failed to mine block")
+	if _, err := wb.RcMngr.EvilMine(); err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return ctx.String(400, err.Error())
 	}
+	n := wb.RcMngr.MainNode()
 	wb.RssLogEvilSend("Mined with node %s in %.2f sec", n.Name, time.Since(t).Seconds())
 	wb.RssNodeAllUpdates(n.Id)
-	wb.RcMngr.EvilBlock = b.Clone()
-	return wb.HandleEvilLoad(ctx)
+	return wb.evilLoad(ctx)
 }
 
 func (wb *EmulatorWeb) HandleEvilInject(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
 	wb.RssLogEvilSend("Injecting evil block")
-	if wb.RcMngr.EvilBlock == nil {
-		return wb.evilBlockSetFail(ctx, "Evil: evil block not set. Steal block first.")
-	}
-	n := wb.RcMngr.MainNode()
-	if n == nil {
-		return wb.evilBlockSetFail(ctx, "Evil: no main node set")
+	if err := wb.RcMngr.EvilInject(); err != nil {
+		return wb.evilBlockSetFail(ctx, err.Error())
 	}
-	n.BlockCandidate = wb.RcMngr.EvilBlock.Clone()
 	return renderTempl(ctx, views.EvilActionResult(true))
 }
 
 func (wb *EmulatorWeb) HandleEvilSend(ctx echo.Context) error {
-	wb.RssLogEvilSend("Injecting evil block")
-	if wb.RcMngr.EvilBlock == nil {
-		return wb.evilBlockSetFail(ctx, "Evil: evil block not set. Steal block first.")
-	}
-	n := wb.RcMngr.MainNode()
-	if n == nil {
-		return wb.evilBlockSetFail(ctx, "Evil: no main node set")
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.RssLogEvilSend("Sending evil block")
+	if _, err := wb.RcMngr.EvilSend(wb.Logger()); err != nil {
+		return wb.evilBlockSetFail(ctx, err.Error())
 	}
-	for id, nd := range wb.RcMngr.Nodes {
-		if id == n.Id {
-			continue
+	wb.RssAllNodesUpdates()
+	return renderTempl(ctx, views.EvilActionResult(true))
+}
+
+// Recomputes stale evil block field given by fix parameter and renders the block
+func (wb *EmulatorWeb) HandleEvilFix(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	fix := ctx.Param("fix")
+	tid := 0
+	if f := ctx.FormValue("tid"); f != "" {
+		var err error
+		if tid, err = strconv.Atoi(f); err != nil {
+			wb.RssLogErrorSend("Evil: transaction id is not integer")
+			return wb.evilLoad(ctx)
 		}
-		if err := n.AddVerifyBlock(wb.RcMngr.EvilBlock); err != nil {
-			wb.RssLogErrorSend("Node [%s] add block failed: %s", nd.Name, err)
+	}
+	if err := wb.RcMngr.EvilFix(fix, tid, ctx.FormValue("addr"), wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+	}
+	return wb.evilLoad(ctx)
+}
+
+// Replays confirmed transaction of node chain or raw transaction into evil block
+func (wb *EmulatorWeb) HandleEvilReplay(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	var err error
+	if raw := ctx.FormValue("raw"); raw != "" {
+		_, err = wb.RcMngr.EvilImportTx(raw, wb.Logger())
+	} else {
+		height, herr := strconv.Atoi(ctx.FormValue("height"))
+		index, ierr := strconv.Atoi(ctx.FormValue("index"))
+		if herr != nil || ierr != nil {
+			err = fmt.Errorf("Evil: block height and transaction index must be integer")
+		} else {
+			_, err = wb.RcMngr.EvilReplay(ctx.FormValue("nodeId"), height, index, wb.Logger())
 		}
 	}
-	wb.RssAllNodesUpdates()
-	return renderTempl(ctx, views.EvilActionResult(true))
+	if err != nil {
+		wb.RssLogErrorSend(err.Error())
+	}
+	return wb.evilLoad(ctx)
+}
+
+// Renders evil block as annotated raw bytes
+func (wb *EmulatorWeb) HandleEvilRaw(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	text, size, err := wb.RcMngr.EvilRawText()
+	if err != nil {
+		return renderTempl(ctx, views.ItemNotFound("Block", err.Error()))
+	}
+	return renderTempl(ctx, views.EvilRaw(views.EvilRawItem{Text: text, Size: strconv.Itoa(size)}))
+}
+
+// Parses edited raw bytes into evil block. On error keeps the edited text
+func (wb *EmulatorWeb) HandleEvilRawApply(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	raw := ctx.FormValue("raw")
+	if err := wb.RcMngr.EvilRawApply(raw, wb.Logger()); err != nil {
+		wb.RssLogErrorSend(err.Error())
+		return renderTempl(ctx, views.EvilRaw(views.EvilRawItem{Text: raw, Size: "?", Error: err.Error()}))
+	}
+	text, size, _ := wb.RcMngr.EvilRawText()
+	return renderTempl(ctx, views.EvilRaw(views.EvilRawItem{Text: text, Size: strconv.Itoa(size)}))
 }
 
 func (wb *EmulatorWeb) evilGetTransaction(tid string) (*ruscoin.Transaction, error) {
@@ -1008,15 +1975,276 @@ func blockToItem(b *ruscoin.Block) views.BlockInfoSmallItem {
 		Root:     b.RootString(),
 		Time:     b.Header.Time.Format("2006-01-02 15:04:05"),
 		TotalTr:  strconv.Itoa(len(b.Body.Transactions)),
+		Size:     strconv.Itoa(b.Size()),
+		Fullness: min(int(b.Fullness()), 100),
 	}
+	bi.FullnessText = fmt.Sprintf("%.1f%% of %d B", b.Fullness(), ruscoin.MAX_BLOCK_SIZE)
 	return bi
 }
 
-func walletToSelectListItems(w *ruscoin.Wallet) views.SelectListItem {
-	return views.SelectListItem{
-		Id:   w.Addr,
-		Name: w.Name,
+func (wb *EmulatorWeb) schedulerToItem() views.SchedulerItem {
+	return views.SchedulerItem{
+		Running:     wb.Sched.Running(),
+		TicksPerMin: strconv.Itoa(wb.Sched.Speed()),
+	}
+}
+
+func (wb *EmulatorWeb) selfishToItem() views.SelfishItem {
+	s := wb.RcMngr.Selfish
+	if s == nil {
+		return views.SelfishItem{}
+	}
+	p := s.Last()
+	si := views.SelfishItem{
+		Running:      true,
+		Node:         s.Node.Name,
+		Gamma:        strconv.FormatFloat(s.Gamma, 'f', 2, 64),
+		Withheld:     strconv.Itoa(s.Withheld()),
+		Published:    strconv.Itoa(len(s.Node.BlockChain) - 1 - s.Withheld()),
+		HashShare:    strconv.FormatFloat(p.HashShare, 'f', 1, 64),
+		RevenueShare: strconv.FormatFloat(p.Share(), 'f', 1, 64),
+	}
+	for _, h := range s.History {
+		si.Revenue = append(si.Revenue, h.Share())
+		si.Hash = append(si.Hash, h.HashShare)
+	}
+	return si
+}
+
+func (wb *EmulatorWeb) networkToItem() views.NetworkItem {
+	rm := wb.RcMngr
+	ni := views.NetworkItem{Mesh: rm.FullMesh()}
+	if p := rm.Partition; p != nil {
+		ni.Partition = "partition"
+		if p.Until > 0 {
+			ni.Partition += fmt.Sprintf(" until tick %d", p.Until)
+		}
+	}
+	if e := rm.Eclipse; e != nil {
+		ni.Eclipse = "eclipse of " + e.Victim.Name
+	}
+	for _, k := range slices.Sorted(maps.Keys(rm.Cuts)) {
+		c := rm.Cuts[k]
+		cut := c.A.Name + " - " + c.B.Name
+		if c.Until > 0 {
+			cut += fmt.Sprintf(" until tick %d", c.Until)
+		}
+		ni.Cuts = append(ni.Cuts, cut)
+	}
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		item := views.NetworkNodeItem{
+			Name:    n.Name,
+			Group:   strconv.Itoa(rm.NodeGroup(id)),
+			Height:  strconv.Itoa(len(n.BlockChain) - 1),
+			Offline: n.Offline,
+		}
+		if b := n.GetLastBlock(); b != nil {
+			item.Tip = b.HashString()[:16]
+		}
+		names := []string{}
+		for _, m := range n.Neighbours {
+			names = append(names, m.Name)
+		}
+		slices.Sort(names)
+		item.Neighbours = strings.Join(names, ", ")
+		if e := rm.Eclipse; e != nil {
+			item.Victim = e.Victim == n
+			item.Attacker = slices.Contains(e.Attackers, n)
+		}
+		ni.Nodes = append(ni.Nodes, item)
+	}
+	slices.SortFunc(ni.Nodes, func(a, b views.NetworkNodeItem) int { return strings.Compare(a.Name, b.Name) })
+	return ni
+}
+
+func (wb *EmulatorWeb) HandleCensorshipStatus(ctx echo.Context) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return renderTempl(ctx, views.CensorshipStatus(wb.censorshipToItem()))
+}
+
+// Number of transaction waits shown in censorship panel
+const CENSORSHIP_WAITS_SHOWN = 20
+
+func (wb *EmulatorWeb) censorshipToItem() views.CensorshipItem {
+	rm := wb.RcMngr
+	ci := views.CensorshipItem{Miners: "none"}
+	miners := []string{}
+	for _, id := range rm.NodeIds() {
+		n := rm.Nodes[id]
+		if n.Policy == nil {
+			continue
+		}
+		list := "all"
+		if len(n.Policy.Blacklist) > 0 {
+			names := []string{}
+			for _, s := range n.Policy.Blacklist {
+				names = append(names, rm.walletLabel(s))
+			}
+			list = strings.Join(names, ", ")
+		}
+		miners = append(miners, fmt.Sprintf("%s (%s)", n.Name, list))
+	}
+	if len(miners) > 0 {
+		ci.Miners = strings.Join(miners, "; ")
+	}
+	pending := 0
+	for _, w := range rm.TxWaits {
+		if w.Mined < 0 {
+			pending++
+		}
+	}
+	ci.Pending = strconv.Itoa(pending)
+	avgC, nc, avgO, no := rm.AverageWaits()
+	ci.AvgCensored = fmt.Sprintf("%.1f ticks (%d tx)", avgC, nc)
+	ci.AvgOther = fmt.Sprintf("%.1f ticks (%d tx)", avgO, no)
+	// newest first
+	for i := len(rm.TxWaits) - 1; i >= 0 && len(ci.Waits) < CENSORSHIP_WAITS_SHOWN; i-- {
+		w := rm.TxWaits[i]
+		ci.Waits = append(ci.Waits, views.TxWaitItem{
+			From:      w.From,
+			To:        w.To,
+			Amount:    strconv.Itoa(w.Amount),
+			Submitted: strconv.Itoa(w.Submitted),
+			Waited:    strconv.Itoa(w.Waited(rm.Tick)),
+			Skipped:   strconv.Itoa(w.Skipped),
+			Censored:  w.Censored,
+			Pending:   w.Mined < 0,
+		})
+	}
+	return ci
+}
+
+func (wb *EmulatorWeb) sybilToItem() views.SybilItem {
+	rm := wb.RcMngr
+	s := rm.Sybil
+	if s == nil {
+		return views.SybilItem{}
+	}
+	si := views.SybilItem{
+		Running:  true,
+		Owner:    s.Owner,
+		Nodes:    strconv.Itoa(len(s.Nodes)),
+		Mode:     s.Mode,
+		Targets:  "all transactions",
+		Censored: strconv.Itoa(s.Censored),
+		Delayed:  strconv.Itoa(s.Delayed),
+		Withheld: strconv.Itoa(s.Withheld),
+		Held:     strconv.Itoa(s.Held()),
+	}
+	if s.Mode == SYBIL_DELAY {
+		si.Mode += fmt.Sprintf(" %d ticks", s.Delay)
+	}
+	if len(s.Targets) > 0 {
+		names := []string{}
+		for _, a := range s.Targets {
+			if w, ok := rm.Wallets[a]; ok {
+				names = append(names, w.Name)
+			} else {
+				names = append(names, a)
+			}
+		}
+		si.Targets = strings.Join(names, ", ")
+	}
+	for _, id := range rm.NodeIds() {
+		if rm.Owners[id] != "" {
+			continue
+		}
+		n := rm.Nodes[id]
+		share := rm.SybilShare(n)
+		si.Victims = append(si.Victims, views.SybilVictimItem{
+			Name:       n.Name,
+			Share:      strconv.FormatFloat(100*share, 'f', 0, 64),
+			Surrounded: share == 1,
+		})
+	}
+	slices.SortFunc(si.Victims, func(a, b views.SybilVictimItem) int { return strings.Compare(a.Name, b.Name) })
+	return si
+}
+
+func (wb *EmulatorWeb) doubleSpendToItem() views.DoubleSpendItem {
+	ds := wb.RcMngr.DoubleSpend
+	if ds == nil {
+		return views.DoubleSpendItem{}
+	}
+	di := views.DoubleSpendItem{
+		Exists:        true,
+		Running:       ds.Running(),
+		Node:          ds.Node.Name,
+		Victim:        ds.Victim.Name,
+		Amount:        strconv.Itoa(ds.Amount),
+		Confirmations: strconv.Itoa(ds.Confirmations),
+		PayHeight:     "-",
+		Current:       strconv.Itoa(ds.FinalConfirmations),
+		PrivateLen:    strconv.Itoa(ds.PrivateLen()),
+		HashShare:     strconv.FormatFloat(100*ds.HashShare, 'f', 1, 64),
+		Result:        ds.Result,
+		Reversed:      ds.Reversed,
+	}
+	if ds.PayHeight >= 0 {
+		di.PayHeight = strconv.Itoa(ds.PayHeight)
+	}
+	if ds.Running() {
+		di.Current = strconv.Itoa(ds.confirmationsAt(wb.RcMngr.publicLen()))
+	}
+	safe := SafeConfirmations(ds.HashShare, DOUBLE_SPEND_RISK)
+	if safe < 0 {
+		di.Safe = "none, attacker has majority"
+	} else {
+		di.Safe = fmt.Sprintf("%d (P < %g)", safe, DOUBLE_SPEND_RISK)
+	}
+	for z, p := range ds.Probabilities(max(ds.Confirmations, min(safe, 30), 10)) {
+		di.Probabilities = append(di.Probabilities, views.DoubleSpendProbItem{
+			Z:    strconv.Itoa(z),
+			P:    strconv.FormatFloat(p, 'f', 6, 64),
+			Used: z == ds.Confirmations,
+			Safe: p < DOUBLE_SPEND_RISK,
+		})
+	}
+	return di
+}
+
+func (wb *EmulatorWeb) scenarioToItem() views.ScenarioItem {
+	r := wb.Scenario
+	if r == nil {
+		return views.ScenarioItem{}
+	}
+	si := views.ScenarioItem{
+		Loaded:      true,
+		Name:        r.Sc.Name,
+		Description: r.Sc.Description,
+		Pos:         r.Pos(),
+		Steps:       make([]views.ScenarioStepItem, len(r.Sc.Steps)),
+	}
+	for i, st := range r.Sc.Steps {
+		si.Steps[i] = views.ScenarioStepItem{
+			N:       strconv.Itoa(i + 1),
+			Action:  st.Action,
+			Comment: st.Comment,
+		}
+	}
+	for _, res := range r.Results {
+		si.Steps[res.Index].Done = true
+		si.Steps[res.Index].OK = res.OK
+		si.Steps[res.Index].Msg = res.Msg
+		if !res.OK {
+			si.Failed++
+		}
+	}
+	return si
+}
+
+func (wb *EmulatorWeb) walletToSelectListItems(w *ruscoin.Wallet) views.SelectListItem {
+	item := views.SelectListItem{
+		Id:      w.Addr,
+		Name:    w.Name,
+		Offline: w.Offline,
+	}
+	if n, ok := wb.RcMngr.Nodes[w.NodeId]; ok {
+		item.Node = n.Name
 	}
+	return item
 }
 
 func blockTransationToItems(b *ruscoin.Block) []views.BlockTransactionItem {
@@ -10,10 +10,19 @@ import (
 	"time"
 )
 
+// Queues event for SSE clients. Event is dropped if the queue is full, e.g. no client reads
+// it, so that emulation never waits for clients
+func (wb *EmulatorWeb) rssSend(e RssEvent) {
+	select {
+	case wb.rssChan <- e:
+	default:
+	}
+}
+
 func (wb *EmulatorWeb) RssLogSend(i int, msg string) {
 	m := renderLogRow(wb.ctx, i, time.Now().Format(glb.LOG_DATE_FORMAT)+msg)
 	e := NewRssEvent().WithEvent([]byte(glb.RSS_LOG_EVENT)).WithData(m)
-	wb.rssChan <- *e
+	wb.rssSend(*e)
 }
 
 func (wb *EmulatorWeb) RssLogInfoSend(msg string, a ...any) {
@@ -29,18 +38,18 @@ func (wb *EmulatorWeb) RssLogEvilSend(msg string, a ...any) {
 	wb.RssLogSend(glb.LOG_LVL_EVIL, fmt.Sprintf(msg, a...))
 }
 
-func (wb *EmulatorWeb) rssSendMinerStatusUpdate(name string, isMain bool) {
+func (wb *EmulatorWeb) rssSendMinerStatusUpdate(id string, isMain bool) {
 	msg := renderViewToBytes(wb.ctx, views.NodeMode(isMain))
 	e := NewRssEvent().
-		WithEvent([]byte(name + glb.RSS_EVENT_MINER_SET)).
+		WithEvent([]byte(id + glb.RSS_EVENT_MINER_SET)).
 		WithData(msg)
-	wb.rssChan <- *e
+	wb.rssSend(*e)
 }
 
-func (wb *EmulatorWeb) RssSendMainMinerUpdates(name string) {
-	wb.rssSendMinerStatusUpdate(name, true)
-	idleNodes := wb.RcMngr.NodeNames()
-	idleNodes = slices.DeleteFunc(idleNodes, func(n string) bool { return n == name })
+func (wb *EmulatorWeb) RssSendMainMinerUpdates(id string) {
+	wb.rssSendMinerStatusUpdate(id, true)
+	idleNodes := wb.RcMngr.NodeIds()
+	idleNodes = slices.DeleteFunc(idleNodes, func(n string) bool { return n == id })
 	for _, n := range idleNodes {
 		wb.rssSendMinerStatusUpdate(n, false)
 	}
@@ -48,9 +57,10 @@ func (wb *EmulatorWeb) RssSendMainMinerUpdates(name string) {
 
 func (wb *EmulatorWeb) RssSendMinerSelect() {
 	if n := wb.RcMngr.mainNode; n != nil {
-		name := n.Name
-		wb.RssLogOKSend("Miner set to " + name)
-		wb.RssSendMainMinerUpdates(name)
+		wb.RssLogOKSend("Miner set to " + n.Name)
+		wb.RssSendMainMinerUpdates(n.Id)
+	} else {
+		wb.RssLogErrorSend("No online nodes to select miner from")
 	}
 }
 
@@ -60,9 +70,9 @@ func (wb *EmulatorWeb) RssSendNodeWalletUpdate(id string) {
 		return
 	}
 	e := NewRssEvent().
-		WithEvent([]byte(node.Name + glb.RSS_EVENT_WALLET_COINS)).
+		WithEvent([]byte(node.Id + glb.RSS_EVENT_WALLET_COINS)).
 		WithData([]byte(strconv.Itoa(node.Wallet.Balance())))
-	wb.rssChan <- *e
+	wb.rssSend(*e)
 }
 
 func (wb *EmulatorWeb) RssSendNodeLastBlock(id string) {
@@ -83,9 +93,9 @@ func (wb *EmulatorWeb) RssSendNodeLastBlock(id string) {
 	}
 	msg := renderViewToBytes(wb.ctx, views.BlockInfoSmall(bsm))
 	e := NewRssEvent().
-		WithEvent([]byte(n.Name + glb.RSS_EVENT_LASTBLOCK)).
+		WithEvent([]byte(n.Id + glb.RSS_EVENT_LASTBLOCK)).
 		WithData([]byte(msg))
-	wb.rssChan <- *e
+	wb.rssSend(*e)
 }
 
 func (wb *EmulatorWeb) RssSendNodeCoinbase(id string) {
@@ -94,9 +104,9 @@ func (wb *EmulatorWeb) RssSendNodeCoinbase(id string) {
 		return
 	}
 	msg := NewRssEvent().
-		WithEvent([]byte(n.Name + glb.RSS_EVENT_NODE_COINBASE)).
+		WithEvent([]byte(n.Id + glb.RSS_EVENT_NODE_COINBASE)).
 		WithData([]byte(fmt.Sprintf("<span>%d</span>", n.CoinbaseUtxoAmount())))
-	wb.rssChan <- *msg
+	wb.rssSend(*msg)
 }
 
 // Send all RSS messages related to node with given id
@@ -119,5 +129,29 @@ func (wb *EmulatorWeb) RssTick() {
 	msg := NewRssEvent().
 		WithEvent([]byte(glb.RSS_EVENT_TICK)).
 		WithData([]byte(strconv.Itoa(wb.RcMngr.Tick)))
-	wb.rssChan <- *msg
+	wb.rssSend(*msg)
+}
+
+func (wb *EmulatorWeb) RssSchedulerState() {
+	msg := renderViewToBytes(wb.ctx, views.SchedulerState(wb.schedulerToItem()))
+	e := NewRssEvent().
+		WithEvent([]byte(glb.RSS_EVENT_SCHED)).
+		WithData(msg)
+	wb.rssSend(*e)
+}
+
+// Asks clients to reload node list
+func (wb *EmulatorWeb) RssNodeListChanged() {
+	e := NewRssEvent().
+		WithEvent([]byte(glb.RSS_EVENT_NODES)).
+		WithData([]byte(strconv.Itoa(len(wb.RcMngr.Nodes))))
+	wb.rssSend(*e)
+}
+
+// Asks clients to reload wallet list
+func (wb *EmulatorWeb) RssWalletListChanged() {
+	e := NewRssEvent().
+		WithEvent([]byte(glb.RSS_EVENT_WALLETS)).
+		WithData([]byte(strconv.Itoa(len(wb.RcMngr.Wallets))))
+	wb.rssSend(*e)
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -18,6 +19,10 @@ var (
 	RSS_READ_UPDATE_TIME        = time.Millisecond * 100
 	OP_PAUSE_MILISEC            = time.Millisecond * 500
 	WITH_LOG                    = false
+	TICKS_PER_MINUTE            = 6
+	SCENARIO_DIR                = "scenarios"
+	// Events queued for SSE clients
+	RSS_QUEUE_SIZE = 1024
 )
 
 type EmulatorWeb struct {
@@ -25,7 +30,11 @@ type EmulatorWeb struct {
 	E                 *echo.Echo
 	rssChan           RssChan
 	RssReadUpdateTime time.Duration
-	ctx               context.Context
+	Sched             *TickScheduler
+	Scenario          *ScenarioRunner
+	// Guards emulation state between tick scheduler and handlers
+	mu  sync.Mutex
+	ctx context.Context
 }
 
 func (e *EmulatorWeb) TestRoutine() {
@@ -39,6 +48,10 @@ func (e *EmulatorWeb) TestRoutine() {
 // RUSCOIN_HTTP_PORT  - port for web server to listen
 //
 // RUSCOIN_RSS_UPDATE - send update period in Milliseconds for RSS messages
+//
+// TICKS_PER_MINUTE   - initial speed of the tick scheduler
+//
+// SCENARIO_DIR       - directory with scenario files
 func LoadSettingsFromEnv() error {
 	errStr := ""
 	if v := os.Getenv("RUSCOIN_HTTP_ADDR"); v != "" {
@@ -67,6 +80,16 @@ func LoadSettingsFromEnv() error {
 			errStr += "Failed to pase OP_PAUSE_MILISEC env variable\n"
 		}
 	}
+	if v := os.Getenv("TICKS_PER_MINUTE"); v != "" {
+		if c, err := strconv.Atoi(v); err == nil && c >= SCHED_TPM_MIN && c <= SCHED_TPM_MAX {
+			TICKS_PER_MINUTE = c
+		} else {
+			errStr += "Failed to pase TICKS_PER_MINUTE env variable\n"
+		}
+	}
+	if v := os.Getenv("SCENARIO_DIR"); v != "" {
+		SCENARIO_DIR = v
+	}
 	if v := os.Getenv("WITH_LOG"); v != "" {
 		if b, err := strconv.ParseBool(v); err == nil {
 			WITH_LOG = b
@@ -82,20 +105,28 @@ func LoadSettingsFromEnv() error {
 }
 
 func NewEmulatorWeb() *EmulatorWeb {
-	return &EmulatorWeb{
-		RcMngr:            NewRuscoinMngr(),
+	wb := &EmulatorWeb{
+		RcMngr:            NewRuscoinMngr().WithOpPause(OP_PAUSE_MILISEC),
 		E:                 echo.New(),
-		rssChan:           make(RssChan),
+		rssChan:           make(RssChan, RSS_QUEUE_SIZE),
 		RssReadUpdateTime: RSS_READ_UPDATE_TIME,
 		ctx:               context.Background(),
 	}
+	wb.Sched = NewTickScheduler(TICKS_PER_MINUTE, wb.Tick).OnStateChange(wb.RssSchedulerState)
+	return wb
 }
 
 func (wb *EmulatorWeb) DefaultRcManager() *EmulatorWeb {
 	wb.RcMngr = DefaultRuscoinMngr()
+	wb.RcMngr.OpPause = OP_PAUSE_MILISEC
 	return wb
 }
 
+// Logger which sends emulation messages to web clients
+func (wb *EmulatorWeb) Logger() EmuLogger {
+	return rssLogger{wb: wb}
+}
+
 func (wb *EmulatorWeb) StartWithLogger() {
 	wb.E.Use(middleware.Logger())
 	wb.E.Logger.Fatal(wb.Start())
@@ -108,6 +139,8 @@ func (wb *EmulatorWeb) Start() error {
 	defer ctxDone()
 	defer close(wb.rssChan)
 
+	go wb.Sched.Run(ctx)
+
 	wb.initRoutes()
 	err := wb.E.Start(HTTP_ADDR + ":" + HTTP_PORT)
 	if err != nil {
@@ -135,6 +168,12 @@ func (wb *EmulatorWeb) initRoutes() {
 
 	wb.E.GET("/tick", wb.HandleTick)
 
+	gSched := wb.E.Group("/sched")
+	gSched.GET("/play", wb.HandleSchedPlay)
+	gSched.GET("/pause", wb.HandleSchedPause)
+	gSched.GET("/step", wb.HandleTick)
+	gSched.POST("/speed", wb.HandleSchedSpeed)
+
 	wb.E.GET("/settings", wb.HandleEimulationSettings)
 
 	gNode := wb.E.Group("/node")
@@ -142,12 +181,68 @@ func (wb *EmulatorWeb) initRoutes() {
 	gNode.POST("/info", wb.HandleNodeInfo)
 	gNode.POST("/block", wb.HandleBlockDetails)
 	gNode.POST("/block/tr", wb.HandleBlockTransactions)
+	gNode.POST("/new", wb.HandleNodeNew)
+	gNode.POST("/rename", wb.HandleNodeRename)
+	gNode.POST("/crash", wb.HandleNodeCrash)
+	gNode.POST("/restore", wb.HandleNodeRestore)
+	gNode.POST("/delete", wb.HandleNodeDelete)
+	gNode.POST("/hashpower", wb.HandleNodeHashPower)
+	gNode.POST("/behaviour", wb.HandleNodeBehaviour)
+	gNode.POST("/clock", wb.HandleNodeClock)
 
 	gWallet := wb.E.Group("/wallet")
 	gWallet.POST("/slist", wb.HandleWalletList)
+	gWallet.GET("/options", wb.HandleWalletOptions)
 	gWallet.POST("/utxotable", wb.HandleWalletUtxoTable)
 	gWallet.POST("/addtr", wb.HandleAddTransaction)
+	gWallet.POST("/bump", wb.HandleWalletBump)
 	gWallet.POST("/blocktr", wb.HandleWalletBlockTr)
+	gWallet.POST("/history", wb.HandleWalletHistory)
+	gWallet.GET("/history.csv", wb.HandleWalletHistoryCSV)
+	gWallet.POST("/new", wb.HandleWalletNew)
+	gWallet.POST("/import", wb.HandleWalletImport)
+	gWallet.POST("/restore", wb.HandleWalletRestore)
+	gWallet.POST("/addrs", wb.HandleWalletAddrs)
+	gWallet.POST("/export", wb.HandleWalletExport)
+	gWallet.POST("/rename", wb.HandleWalletRename)
+	gWallet.POST("/offline", wb.HandleWalletOffline)
+	gWallet.POST("/online", wb.HandleWalletOnline)
+	gWallet.POST("/attach", wb.HandleWalletAttach)
+	gWallet.POST("/rescan", wb.HandleWalletRescan)
+	gWallet.GET("/multisig", wb.HandleMultisigTable)
+	gWallet.POST("/multisig/new", wb.HandleMultisigNew)
+	gWallet.POST("/multisig/spend", wb.HandleMultisigSpend)
+	gWallet.POST("/multisig/sign", wb.HandleMultisigSign)
+	gWallet.POST("/multisig/submit", wb.HandleMultisigSubmit)
+
+	gAttack := wb.E.Group("/attack")
+	gAttack.POST("/selfish/start", wb.HandleSelfishStart)
+	gAttack.POST("/selfish/stop", wb.HandleSelfishStop)
+	gAttack.GET("/selfish/status", wb.HandleSelfishStatus)
+	gAttack.POST("/doublespend/start", wb.HandleDoubleSpendStart)
+	gAttack.POST("/doublespend/stop", wb.HandleDoubleSpendStop)
+	gAttack.GET("/doublespend/status", wb.HandleDoubleSpendStatus)
+	gAttack.POST("/sybil/start", wb.HandleSybilStart)
+	gAttack.POST("/sybil/stop", wb.HandleSybilStop)
+	gAttack.GET("/sybil/status", wb.HandleSybilStatus)
+	gAttack.GET("/censorship/status", wb.HandleCensorshipStatus)
+
+	gNetwork := wb.E.Group("/network")
+	gNetwork.GET("/status", wb.HandleNetworkStatus)
+	gNetwork.GET("/checks", wb.HandleNetworkChecks)
+	gNetwork.POST("/link", wb.HandleNetworkLink)
+	gNetwork.POST("/unlink", wb.HandleNetworkUnlink)
+	gNetwork.POST("/cut", wb.HandleNetworkCut)
+	gNetwork.POST("/split", wb.HandleNetworkSplit)
+	gNetwork.POST("/eclipse", wb.HandleNetworkEclipse)
+	gNetwork.POST("/heal", wb.HandleNetworkHeal)
+
+	gScenario := wb.E.Group("/scenario")
+	gScenario.GET("", wb.HandleScenarioTab)
+	gScenario.GET("/status", wb.HandleScenarioStatus)
+	gScenario.POST("/load", wb.HandleScenarioLoad)
+	gScenario.POST("/step", wb.HandleScenarioStep)
+	gScenario.POST("/run", wb.HandleScenarioRun)
 
 	gEvil := wb.E.Group("/evil")
 	gEvil.GET("/load", wb.HandleEvilLoad)
@@ -173,4 +268,9 @@ func (wb *EmulatorWeb) initRoutes() {
 	gEvilAdd := gEvil.Group("/add")
 	gEvilAdd.GET("/tr", wb.HandleEvilAddTr)
 	gEvilAdd.POST("/utxo", wb.HandleEvilAddUtxo)
+
+	gEvil.POST("/fix/:fix", wb.HandleEvilFix)
+	gEvil.GET("/raw", wb.HandleEvilRaw)
+	gEvil.POST("/replay", wb.HandleEvilReplay)
+	gEvil.POST("/raw", wb.HandleEvilRawApply)
 }
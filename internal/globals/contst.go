@@ -20,4 +20,7 @@ const (
 	RSS_EVENT_LASTBLOCK       = "rssLB"
 	RSS_EVENT_NODE_COINBASE   = "rssNCB"
 	RSS_EVENT_TICK            = "rssTick"
+	RSS_EVENT_SCHED           = "rssSched"
+	RSS_EVENT_NODES           = "rssNodes"
+	RSS_EVENT_WALLETS         = "rssWallets"
 )
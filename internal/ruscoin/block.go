@@ -27,7 +27,7 @@ type Block struct {
 func NewBlock() *Block {
 	return &Block{
 		Header: BlockHeader{
-			Time: time.Now(),
+			Time: TimeSource.Now(),
 			Root: nil,
 			Prev: nil,
 			Hash: nil,
@@ -42,7 +42,7 @@ func NewGenesisBlock() *Block {
 	b := &Block{
 		Header: BlockHeader{
 			Height: 0,
-			Time:   time.Now(),
+			Time:   TimeSource.Now(),
 			Root:   []byte{},
 			Prev:   []byte{byte(GENESIS_BLOCK_PREV)},
 			Nonce:  0,
@@ -110,6 +110,33 @@ func (b *Block) PrevString() string {
 // 	panic("Block: AddRewardTransaction: must move to node")
 // }
 
+// Serialised block size in bytes
+func (b *Block) Size() int {
+	return len(b.Serialize())
+}
+
+// Share of MAX_BLOCK_SIZE taken by the block in percents
+func (b *Block) Fullness() float64 {
+	return 100 * float64(b.Size()) / float64(MAX_BLOCK_SIZE)
+}
+
+// Fees of block transactions paid to the miner with the reward. Genesis block has no fees.
+// Transactions creating coins are rejected by block verification and do not lower fees
+func (b *Block) Fees() int {
+	if b.Header.Height == 0 || len(b.Body.Transactions) == 0 {
+		return 0
+	}
+	return transactionFees(b.Body.Transactions[1:])
+}
+
+func transactionFees(txs []Transaction) int {
+	fees := 0
+	for i := range txs {
+		fees += max(txs[i].Fee(), 0)
+	}
+	return fees
+}
+
 func (b *Block) AddTransaction(t Transaction) int {
 	b.Body.Transactions = append(b.Body.Transactions, t.Clone())
 	return len(b.Body.Transactions) - 1
@@ -0,0 +1,35 @@
+package ruscoin
+
+import (
+	"slices"
+	"time"
+)
+
+// Time on the node clock: common time source shifted by node clock skew
+func (n *Node) Now() time.Time {
+	return TimeSource.Now().Add(n.ClockSkew)
+}
+
+// Median time of the last MTP_BLOCKS blocks of the node chain, zero time for empty chain
+func (n *Node) MedianTimePast() time.Time {
+	return MedianTimePast(n.BlockChain)
+}
+
+// Median time of the last MTP_BLOCKS blocks of the chain, zero time for empty chain
+func MedianTimePast(chain []*Block) time.Time {
+	if len(chain) == 0 {
+		return time.Time{}
+	}
+	last := chain[max(len(chain)-MTP_BLOCKS, 0):]
+	times := make([]time.Time, len(last))
+	for i, b := range last {
+		times[i] = b.Header.Time
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	return times[len(times)/2]
+}
+
+// Block time is not too far ahead of the node clock
+func (n *Node) checkFutureDrift(b *Block) bool {
+	return !b.Header.Time.After(n.Now().Add(MAX_FUTURE_DRIFT))
+}
@@ -0,0 +1,118 @@
+package ruscoin
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Coin selection strategies of Wallet.Send
+const (
+	// Biggest utxo first, fewest inputs
+	SELECT_LARGEST = "largest"
+	// Smallest utxo first, consolidates dust
+	SELECT_SMALLEST = "smallest"
+	// Branch and bound search of inputs summing exactly to the amount, so no change
+	// output is needed. Falls back to largest first if there is no such set
+	SELECT_BNB = "bnb"
+)
+
+var CoinSelections = []string{SELECT_LARGEST, SELECT_SMALLEST, SELECT_BNB}
+
+// Max number of branches tried by branch and bound selection
+const BNB_MAX_TRIES = 100000
+
+// Chooses ids of utxos whose sum is at least target
+type CoinSelector func(ul UtxoList, target int) ([]string, error)
+
+// Coin selector by strategy name, largest first if name is empty
+func NewCoinSelector(strategy string) (CoinSelector, error) {
+	switch strategy {
+	case "", SELECT_LARGEST:
+		return SelectLargestFirst, nil
+	case SELECT_SMALLEST:
+		return SelectSmallestFirst, nil
+	case SELECT_BNB:
+		return SelectBranchAndBound, nil
+	}
+	return nil, fmt.Errorf("CoinSelector: unknown strategy %s", strategy)
+}
+
+type coin struct {
+	id     string
+	amount int
+}
+
+// Utxos sorted by amount, descending if desc is set. Equal amounts are sorted by id
+func sortedCoins(ul UtxoList, desc bool) []coin {
+	coins := make([]coin, 0, len(ul))
+	for id, u := range ul {
+		coins = append(coins, coin{id, u.Amount})
+	}
+	slices.SortFunc(coins, func(a, b coin) int {
+		c := cmp.Compare(a.amount, b.amount)
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.id, b.id)
+		}
+		return c
+	})
+	return coins
+}
+
+func selectInOrder(coins []coin, target int) ([]string, error) {
+	ids := []string{}
+	sum := 0
+	for _, c := range coins {
+		if sum >= target {
+			break
+		}
+		ids = append(ids, c.id)
+		sum += c.amount
+	}
+	if sum < target {
+		return nil, fmt.Errorf("CoinSelector: not enough coins: have %d, need %d", sum, target)
+	}
+	return ids, nil
+}
+
+func SelectLargestFirst(ul UtxoList, target int) ([]string, error) {
+	return selectInOrder(sortedCoins(ul, true), target)
+}
+
+func SelectSmallestFirst(ul UtxoList, target int) ([]string, error) {
+	return selectInOrder(sortedCoins(ul, false), target)
+}
+
+func SelectBranchAndBound(ul UtxoList, target int) ([]string, error) {
+	coins := sortedCoins(ul, true)
+	// rest[i] is sum of coins[i:]
+	rest := make([]int, len(coins)+1)
+	for i := len(coins) - 1; i >= 0; i-- {
+		rest[i] = rest[i+1] + coins[i].amount
+	}
+	picked := []string{}
+	tries := 0
+	var search func(i, sum int) bool
+	search = func(i, sum int) bool {
+		tries++
+		switch {
+		case sum == target:
+			return true
+		case sum > target || sum+rest[i] < target || i == len(coins) || tries > BNB_MAX_TRIES:
+			return false
+		}
+		picked = append(picked, coins[i].id)
+		if search(i+1, sum+coins[i].amount) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+		return search(i+1, sum)
+	}
+	if target > 0 && search(0, 0) {
+		return picked, nil
+	}
+	return selectInOrder(coins, target)
+}
@@ -0,0 +1,269 @@
+package ruscoin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Binary block layout. Integers are 8 bytes big endian, counts are 4 bytes,
+// byte strings (hashes, signs, utxo ids and addresses) have 2 bytes length prefix.
+// Block time is unix seconds, as in the hashed header.
+//
+//	height time root prev nonce hash coinbase tx_count tx...
+//	tx: in_count utxo... out_count utxo... sign pk multisig_count multisig...
+//	utxo: id addr amount
+//	multisig: m key_count (pk sign)...
+
+// Named byte range of serialised block
+type RawField struct {
+	Name   string
+	Offset int
+	Size   int
+}
+
+// Serialised block
+func (b *Block) Serialize() []byte {
+	w := &rawWriter{}
+	w.block(b)
+	return w.buf.Bytes()
+}
+
+// Serialised block with byte ranges of every field
+func (b *Block) SerializeAnnotated() ([]byte, []RawField) {
+	w := &rawWriter{annotate: true}
+	w.block(b)
+	return w.buf.Bytes(), w.fields
+}
+
+// Serialised transaction
+func (t *Transaction) Serialize() []byte {
+	w := &rawWriter{}
+	w.transaction("tx", t)
+	return w.buf.Bytes()
+}
+
+// Parses serialised block. Error tells offset and field which failed
+func ParseBlock(data []byte) (*Block, error) {
+	r := &rawReader{fn: "ParseBlock", data: data}
+	b := r.block()
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Parses serialised transaction
+func ParseTransaction(data []byte) (*Transaction, error) {
+	r := &rawReader{fn: "ParseTransaction", data: data}
+	t := r.transaction("tx")
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+type rawWriter struct {
+	buf      bytes.Buffer
+	annotate bool
+	fields   []RawField
+}
+
+func (w *rawWriter) put(name string, b []byte) {
+	if w.annotate {
+		w.fields = append(w.fields, RawField{Name: name, Offset: w.buf.Len(), Size: len(b)})
+	}
+	w.buf.Write(b)
+}
+
+func (w *rawWriter) int(name string, v int) {
+	w.put(name, IntToBytes(v))
+}
+
+func (w *rawWriter) count(name string, n int) {
+	w.put(name, binary.BigEndian.AppendUint32(nil, uint32(n)))
+}
+
+func (w *rawWriter) bytes(name string, b []byte) {
+	w.put(name+" length", binary.BigEndian.AppendUint16(nil, uint16(len(b))))
+	if len(b) > 0 {
+		w.put(name, b)
+	}
+}
+
+func (w *rawWriter) block(b *Block) {
+	w.int("height", b.Header.Height)
+	w.int("time", int(b.Header.Time.Unix()))
+	w.bytes("root", b.Header.Root)
+	w.bytes("prev", b.Header.Prev)
+	w.int("nonce", b.Header.Nonce)
+	w.bytes("hash", b.Header.Hash)
+	w.int("coinbase", b.Body.Coinbase)
+	w.count("transactions", len(b.Body.Transactions))
+	for i := range b.Body.Transactions {
+		w.transaction(fmt.Sprintf("tx[%d]", i), &b.Body.Transactions[i])
+	}
+}
+
+func (w *rawWriter) transaction(name string, t *Transaction) {
+	w.utxoList(name+".in", t.InputUtxo)
+	w.utxoList(name+".out", t.OutputUtxo)
+	w.bytes(name+".sign", t.Sign)
+	w.bytes(name+".pk", t.Pk)
+	w.count(name+".multisig count", len(t.Multisig))
+	for i, s := range t.Multisig {
+		mn := fmt.Sprintf("%s.multisig[%d]", name, i)
+		w.int(mn+".m", s.M)
+		w.count(mn+".keys count", len(s.PubKeys))
+		for j, pk := range s.PubKeys {
+			var sig []byte
+			if j < len(s.Signs) {
+				sig = s.Signs[j]
+			}
+			w.bytes(fmt.Sprintf("%s.pk[%d]", mn, j), pk)
+			w.bytes(fmt.Sprintf("%s.sign[%d]", mn, j), sig)
+		}
+	}
+}
+
+func (w *rawWriter) utxoList(name string, ul UtxoList) {
+	w.count(name+" count", len(ul))
+	i := 0
+	for id, u := range ul.SortedItems() {
+		un := fmt.Sprintf("%s[%d]", name, i)
+		w.bytes(un+".id", []byte(id))
+		w.bytes(un+".addr", []byte(u.Addr))
+		w.int(un+".amount", u.Amount)
+		i++
+	}
+}
+
+// Reader keeps the first error, all reads after it return zero values
+type rawReader struct {
+	fn   string
+	data []byte
+	pos  int
+	err  error
+}
+
+// Error of reading or about trailing bytes
+func (r *rawReader) finish() error {
+	if r.err == nil && r.pos != len(r.data) {
+		r.err = r.errorf(r.pos, "%d trailing bytes", len(r.data)-r.pos)
+	}
+	return r.err
+}
+
+func (r *rawReader) errorf(pos int, msg string, a ...any) error {
+	return fmt.Errorf("%s: offset %d: %s", r.fn, pos, fmt.Sprintf(msg, a...))
+}
+
+func (r *rawReader) take(name string, n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data)-r.pos {
+		r.err = r.errorf(r.pos, "%s: need %d bytes, %d left", name, n, len(r.data)-r.pos)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *rawReader) int(name string) int {
+	b := r.take(name, 8)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(b))
+}
+
+// Count of items of at least min bytes each, must fit into the rest of data
+func (r *rawReader) count(name string, min int) int {
+	b := r.take(name, 4)
+	if b == nil {
+		return 0
+	}
+	n := int(binary.BigEndian.Uint32(b))
+	if n*min > len(r.data)-r.pos {
+		r.err = r.errorf(r.pos-4, "%s: %d items do not fit into %d bytes", name, n, len(r.data)-r.pos)
+		return 0
+	}
+	return n
+}
+
+func (r *rawReader) bytes(name string) []byte {
+	b := r.take(name+" length", 2)
+	if b == nil {
+		return nil
+	}
+	v := r.take(name, int(binary.BigEndian.Uint16(b)))
+	if v == nil {
+		return nil
+	}
+	return bytes.Clone(v)
+}
+
+func (r *rawReader) block() *Block {
+	b := &Block{}
+	b.Header.Height = r.int("height")
+	b.Header.Time = time.Unix(int64(r.int("time")), 0).UTC()
+	b.Header.Root = r.bytes("root")
+	b.Header.Prev = r.bytes("prev")
+	b.Header.Nonce = r.int("nonce")
+	b.Header.Hash = r.bytes("hash")
+	b.Body.Coinbase = r.int("coinbase")
+	// empty transaction takes 16 bytes
+	n := r.count("transactions", 16)
+	b.Body.Transactions = make([]Transaction, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		b.Body.Transactions = append(b.Body.Transactions, r.transaction(fmt.Sprintf("tx[%d]", i)))
+	}
+	return b
+}
+
+func (r *rawReader) transaction(name string) Transaction {
+	t := Transaction{
+		InputUtxo:  r.utxoList(name + ".in"),
+		OutputUtxo: r.utxoList(name + ".out"),
+		Sign:       r.bytes(name + ".sign"),
+		Pk:         r.bytes(name + ".pk"),
+	}
+	// script with no keys takes 12 bytes, key with empty sign 4 bytes
+	n := r.count(name+".multisig count", 12)
+	for i := 0; i < n && r.err == nil; i++ {
+		mn := fmt.Sprintf("%s.multisig[%d]", name, i)
+		s := MultisigSign{Multisig: Multisig{M: r.int(mn + ".m")}}
+		k := r.count(mn+".keys count", 4)
+		for j := 0; j < k && r.err == nil; j++ {
+			s.PubKeys = append(s.PubKeys, r.bytes(fmt.Sprintf("%s.pk[%d]", mn, j)))
+			s.Signs = append(s.Signs, r.bytes(fmt.Sprintf("%s.sign[%d]", mn, j)))
+		}
+		t.Multisig = append(t.Multisig, s)
+	}
+	return t
+}
+
+func (r *rawReader) utxoList(name string) UtxoList {
+	// utxo with empty id and address takes 12 bytes
+	n := r.count(name+" count", 12)
+	ul := NewUtxoList()
+	for i := 0; i < n && r.err == nil; i++ {
+		un := fmt.Sprintf("%s[%d]", name, i)
+		start := r.pos
+		id := string(r.bytes(un + ".id"))
+		addr := string(r.bytes(un + ".addr"))
+		amount := r.int(un + ".amount")
+		if r.err != nil {
+			break
+		}
+		if _, ok := ul[id]; ok {
+			r.err = r.errorf(start, "%s: duplicate utxo id %s", un, id)
+			break
+		}
+		ul.Put(id, addr, amount)
+	}
+	return ul
+}
@@ -2,7 +2,6 @@ package ruscoin
 
 import (
 	"bytes"
-	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -27,8 +26,21 @@ func NewSigner() (*Signer, error) {
 	return s, nil
 }
 
+// Signer with private key from raw little-endian bytes as returned by PrivateKey.Raw
+func NewSignerFromKey(raw []byte) (*Signer, error) {
+	prv, err := gost3410.NewPrivateKey(gost3410.CurveDefault(), gost3410.Mode2012, raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid private key: %s", err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate public key")
+	}
+	return &Signer{prvKey: prv, PubKey: pub}, nil
+}
+
 func (s *Signer) Sign(msg []byte) ([]byte, error) {
-	sig, err := s.prvKey.Sign(rand.Reader, msg, nil)
+	sig, err := s.prvKey.Sign(SignSource, msg, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to sign message")
 	}
@@ -94,7 +106,9 @@ func GetHashGost3411(inp []byte) ([]byte, error) {
 func genKeys() (*gost3410.PrivateKey, *gost3410.PublicKey, error) {
 	curve := gost3410.CurveDefault()
 	raw := make([]byte, gost3410.Mode2012)
-	_, err := io.ReadFull(rand.Reader, raw)
+	if _, err := io.ReadFull(KeySource, raw); err != nil {
+		return nil, nil, fmt.Errorf("Failed to read random for private key")
+	}
 	prv, err := gost3410.NewPrivateKey(curve, gost3410.Mode2012, raw)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Failed to generate private key")
@@ -0,0 +1,216 @@
+package ruscoin
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/tyler-smith/go-bip39/wordlists"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Seed phrase of hierarchical deterministic wallet: BIP39 english words for 128 bit
+// entropy and 4 bit checksum. Checksum is Streebog-256 instead of SHA-256
+const (
+	HD_ENTROPY_BITS    = 128
+	HD_MNEMONIC_WORDS  = (HD_ENTROPY_BITS + HD_ENTROPY_BITS/32) / 11
+	HD_SEED_ITERATIONS = 2048
+)
+
+// Number of unused addresses the wallet keeps derived after the last used one on every
+// branch. Restored wallet finds funds only if there are no longer gaps
+const HD_GAP_LIMIT = 5
+
+// Address branches of HD wallet: m/0'/0'/i' receiving and m/0'/1'/i' change addresses
+const (
+	HD_RECEIVE = 0
+	HD_CHANGE  = 1
+)
+
+// Key of BIP32 master key HMAC
+var hdMasterSalt = []byte("Ruscoin seed")
+
+// New random seed phrase
+func NewMnemonic() (string, error) {
+	entropy := make([]byte, HD_ENTROPY_BITS/8)
+	if _, err := io.ReadFull(KeySource, entropy); err != nil {
+		return "", fmt.Errorf("Failed to read random for seed phrase")
+	}
+	h, err := GetHashGost3411(entropy)
+	if err != nil {
+		return "", err
+	}
+	// entropy bits followed by checksum bits, 11 bits per word
+	bits := new(big.Int).SetBytes(entropy)
+	cs := HD_ENTROPY_BITS / 32
+	bits.Lsh(bits, uint(cs))
+	bits.Or(bits, big.NewInt(int64(h[0]>>(8-cs))))
+	words := make([]string, HD_MNEMONIC_WORDS)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = wordlists.English[new(big.Int).And(bits, mask).Int64()]
+		bits.Rsh(bits, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Seed phrase in canonical form: lower case words separated by single spaces. Fails on
+// unknown words, wrong number of words or wrong checksum
+func NormalizeMnemonic(m string) (string, error) {
+	words := strings.Fields(strings.ToLower(m))
+	if len(words) != HD_MNEMONIC_WORDS {
+		return "", fmt.Errorf("Seed phrase must have %d words, got %d", HD_MNEMONIC_WORDS, len(words))
+	}
+	bits := new(big.Int)
+	for _, w := range words {
+		i := slices.Index(wordlists.English, w)
+		if i < 0 {
+			return "", fmt.Errorf("Unknown seed phrase word %q", w)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(i)))
+	}
+	cs := HD_ENTROPY_BITS / 32
+	sum := new(big.Int).And(bits, big.NewInt(1<<cs-1)).Int64()
+	entropy := bits.Rsh(bits, uint(cs)).FillBytes(make([]byte, HD_ENTROPY_BITS/8))
+	h, err := GetHashGost3411(entropy)
+	if err != nil {
+		return "", err
+	}
+	if int64(h[0]>>(8-cs)) != sum {
+		return "", fmt.Errorf("Seed phrase checksum mismatch")
+	}
+	return strings.Join(words, " "), nil
+}
+
+// 64 byte seed of the phrase: PBKDF2-HMAC-Streebog-512 as BIP39 does with SHA-512
+func MnemonicSeed(m string) []byte {
+	return pbkdf2.Key([]byte(m), []byte("mnemonic"), HD_SEED_ITERATIONS, 64, gost34112012512.New)
+}
+
+// Extended private key: key and chain code
+type hdKey struct {
+	key   *big.Int
+	chain []byte
+}
+
+func hdHmac(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(gost34112012512.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// Master key of the seed, BIP32 with HMAC-Streebog-512 and GOST 34.10 curve order
+func hdMasterKey(seed []byte) (hdKey, error) {
+	i := hdHmac(hdMasterSalt, seed)
+	k := new(big.Int).SetBytes(i[:32])
+	if k.Sign() == 0 || k.Cmp(gost3410.CurveDefault().Q) >= 0 {
+		return hdKey{}, fmt.Errorf("Invalid master key, use other seed phrase")
+	}
+	return hdKey{key: k, chain: i[32:]}, nil
+}
+
+// Hardened child key. Wallet address is the hash of the private key, so there is no
+// public derivation and every level is hardened. Invalid index is skipped, as in BIP32
+func (k hdKey) child(index uint32) (hdKey, uint32) {
+	q := gost3410.CurveDefault().Q
+	for ; ; index++ {
+		idx := make([]byte, 4)
+		binary.BigEndian.PutUint32(idx, index|0x80000000)
+		i := hdHmac(k.chain, []byte{0}, k.key.FillBytes(make([]byte, 32)), idx)
+		il := new(big.Int).SetBytes(i[:32])
+		if il.Cmp(q) >= 0 {
+			continue
+		}
+		c := il.Add(il, k.key)
+		c.Mod(c, q)
+		if c.Sign() != 0 {
+			return hdKey{key: c, chain: i[32:]}, index
+		}
+	}
+}
+
+func (k hdKey) signer() *Signer {
+	prv := &gost3410.PrivateKey{C: gost3410.CurveDefault(), Mode: gost3410.Mode2012, Key: k.key}
+	pub, _ := prv.PublicKey()
+	return &Signer{prvKey: prv, PubKey: pub}
+}
+
+// Derived addresses of HD wallet
+type hdWallet struct {
+	mnemonic string
+	branches [2]hdKey
+	// Child index of the next derived key per branch
+	next [2]uint32
+	// Derived addresses per branch
+	addrs [2][]string
+	keys  map[string]*Signer
+	used  map[string]bool
+}
+
+func newHDWallet(mnemonic string) (*hdWallet, error) {
+	m, err := hdMasterKey(MnemonicSeed(mnemonic))
+	if err != nil {
+		return nil, err
+	}
+	account, _ := m.child(0)
+	hd := &hdWallet{mnemonic: mnemonic, keys: map[string]*Signer{}, used: map[string]bool{}}
+	for b := range hd.branches {
+		hd.branches[b], _ = account.child(uint32(b))
+		hd.extend(b)
+	}
+	return hd, nil
+}
+
+// Derives addresses until HD_GAP_LIMIT of them follow the last used one
+func (hd *hdWallet) extend(branch int) {
+	last := -1
+	for i, a := range hd.addrs[branch] {
+		if hd.used[a] {
+			last = i
+		}
+	}
+	for len(hd.addrs[branch])-1-last < HD_GAP_LIMIT {
+		k, idx := hd.branches[branch].child(hd.next[branch])
+		hd.next[branch] = idx + 1
+		s := k.signer()
+		addr, _ := GetHashGost3411(s.prvKey.Raw())
+		a := BytesToString(addr)
+		hd.keys[a] = s
+		hd.addrs[branch] = append(hd.addrs[branch], a)
+	}
+}
+
+// Marks address used and derives more addresses of its branch. Returns false for
+// addresses not derived by the wallet
+func (hd *hdWallet) markUsed(addr string) bool {
+	for b, addrs := range hd.addrs {
+		if slices.Contains(addrs, addr) {
+			if !hd.used[addr] {
+				hd.used[addr] = true
+				hd.extend(b)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// The first unused address of the branch. extend keeps unused addresses after the last
+// used one, so there is always one
+func (hd *hdWallet) unused(branch int) string {
+	for _, a := range hd.addrs[branch] {
+		if !hd.used[a] {
+			return a
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package ruscoin
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Position of transaction in node chain
+type TxRef struct {
+	Height int
+	Index  int
+}
+
+// Adds transaction to index of every address it spends from or pays to
+func (n *Node) indexTransaction(t *Transaction, ref TxRef) {
+	addrs := []string{}
+	for _, u := range t.InputUtxo {
+		addrs = append(addrs, u.Addr)
+	}
+	for _, u := range t.OutputUtxo {
+		addrs = append(addrs, u.Addr)
+	}
+	slices.Sort(addrs)
+	for _, a := range slices.Compact(addrs) {
+		if a != COINBASE_ADDR {
+			n.AddrIndex[a] = append(n.AddrIndex[a], ref)
+		}
+	}
+}
+
+// Chain transaction from the point of view of one address
+type HistoryEntry struct {
+	TxRef
+	Time time.Time
+	Tx   *Transaction
+	// Sum of address inputs and outputs
+	Sent     int
+	Received int
+	// Fee of transaction spending address utxo
+	Fee int
+	// Recipients of outgoing transaction or senders of incoming one
+	Counterparties []string
+	// Address balance after the transaction
+	Balance int
+}
+
+// Change of the address balance
+func (e *HistoryEntry) Amount() int {
+	return e.Received - e.Sent
+}
+
+// Transactions of the address in node chain, oldest first, with running balance
+func (n *Node) AddressHistory(addr string) []HistoryEntry {
+	return n.history(n.AddrIndex[addr], func(a string) bool { return a == addr })
+}
+
+// Transactions of all wallet addresses in node chain, oldest first, with running balance
+func (n *Node) WalletHistory(w *Wallet) []HistoryEntry {
+	refs := []TxRef{}
+	for _, a := range w.Addrs() {
+		refs = append(refs, n.AddrIndex[a.Addr]...)
+	}
+	slices.SortFunc(refs, func(a, b TxRef) int {
+		return cmp.Or(cmp.Compare(a.Height, b.Height), cmp.Compare(a.Index, b.Index))
+	})
+	return n.history(slices.Compact(refs), w.Owns)
+}
+
+// History entries of transactions refs for addresses accepted by owns
+func (n *Node) history(refs []TxRef, owns func(string) bool) []HistoryEntry {
+	res := []HistoryEntry{}
+	balance := 0
+	for _, ref := range refs {
+		b := n.BlockChain[ref.Height]
+		t := &b.Body.Transactions[ref.Index]
+		e := HistoryEntry{TxRef: ref, Time: b.Header.Time, Tx: t}
+		others := t.InputUtxo
+		for _, u := range t.InputUtxo {
+			if owns(u.Addr) {
+				e.Sent += u.Amount
+			}
+		}
+		for _, u := range t.OutputUtxo {
+			if owns(u.Addr) {
+				e.Received += u.Amount
+			}
+		}
+		if e.Sent > 0 {
+			e.Fee = t.Fee()
+			others = t.OutputUtxo
+		}
+		for _, u := range others.SortedItems() {
+			if !owns(u.Addr) && !slices.Contains(e.Counterparties, u.Addr) {
+				e.Counterparties = append(e.Counterparties, u.Addr)
+			}
+		}
+		balance += e.Amount()
+		e.Balance = balance
+		res = append(res, e)
+	}
+	return res
+}
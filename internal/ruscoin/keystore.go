@@ -0,0 +1,162 @@
+package ruscoin
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/mgm"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Keystore format version
+const KEYSTORE_VERSION = 1
+
+// Key derivation and cipher names of keystore version 1
+const (
+	KEYSTORE_KDF    = "pbkdf2-streebog256"
+	KEYSTORE_CIPHER = "kuznyechik-mgm"
+)
+
+// PBKDF2 iterations of exported keys. Import takes iterations from the keystore up to
+// KEYSTORE_MAX_ITERATIONS
+const (
+	KEYSTORE_ITERATIONS     = 2000
+	KEYSTORE_MAX_ITERATIONS = 1000000
+)
+
+const (
+	keystoreSaltSize = 16
+	keystoreKeySize  = 32
+)
+
+// Wallet private key encrypted with passphrase. JSON document, all binary values are hex:
+//
+//	{
+//	  "version": 1,
+//	  "name": "Alice",
+//	  "address": "<wallet address>",
+//	  "kdf": "pbkdf2-streebog256",
+//	  "salt": "<16 bytes>",
+//	  "iterations": 2000,
+//	  "cipher": "kuznyechik-mgm",
+//	  "nonce": "<16 bytes>",
+//	  "ciphertext": "<encrypted raw GOST 34.10-2012 private key and 16 bytes tag>"
+//	}
+//
+// Encryption key is PBKDF2-HMAC-Streebog-256 of the passphrase, the key is sealed by
+// Kuznyechik in MGM mode with the address as additional data, so neither the key nor
+// the address can be changed without the passphrase
+type Keystore struct {
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Kdf        string `json:"kdf"`
+	Salt       string `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Cipher     string `json:"cipher"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+func keystoreAEAD(pass string, salt []byte, iter int) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(pass), salt, iter, keystoreKeySize, gost34112012256.New)
+	aead, err := mgm.NewMGM(gost3412128.NewCipher(key), gost3412128.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("Keystore: failed to create cipher")
+	}
+	return aead, nil
+}
+
+// Wallet private key encrypted with passphrase as keystore JSON
+func (w *Wallet) ExportKey(pass string) ([]byte, error) {
+	if w.hd != nil {
+		return nil, w.Error("ExportKey", "HD wallet is restored from its seed phrase")
+	}
+	if pass == "" {
+		return nil, w.Error("ExportKey", "passphrase is empty")
+	}
+	salt := make([]byte, keystoreSaltSize)
+	nonce := make([]byte, gost3412128.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, w.Error("ExportKey", "failed to read random salt")
+	}
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, w.Error("ExportKey", "failed to read random nonce")
+	}
+	// MGM nonce must have the highest bit cleared
+	nonce[0] &= 0x7f
+	aead, err := keystoreAEAD(pass, salt, KEYSTORE_ITERATIONS)
+	if err != nil {
+		return nil, w.Error("ExportKey", err.Error())
+	}
+	ks := Keystore{
+		Version:    KEYSTORE_VERSION,
+		Name:       w.Name,
+		Address:    w.Addr,
+		Kdf:        KEYSTORE_KDF,
+		Salt:       hex.EncodeToString(salt),
+		Iterations: KEYSTORE_ITERATIONS,
+		Cipher:     KEYSTORE_CIPHER,
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, w.S.prvKey.Raw(), []byte(w.Addr))),
+	}
+	return json.MarshalIndent(ks, "", "  ")
+}
+
+// Wallet with private key decrypted from keystore JSON. Empty name keeps keystore name
+func ImportWallet(name string, data []byte, pass string) (*Wallet, error) {
+	var ks Keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("Keystore: invalid JSON: %s", err)
+	}
+	if ks.Version != KEYSTORE_VERSION {
+		return nil, fmt.Errorf("Keystore: unsupported version %d", ks.Version)
+	}
+	if ks.Kdf != KEYSTORE_KDF || ks.Cipher != KEYSTORE_CIPHER {
+		return nil, fmt.Errorf("Keystore: unsupported kdf %s or cipher %s", ks.Kdf, ks.Cipher)
+	}
+	if ks.Iterations < 1 || ks.Iterations > KEYSTORE_MAX_ITERATIONS {
+		return nil, fmt.Errorf("Keystore: invalid iterations %d", ks.Iterations)
+	}
+	salt, err := hex.DecodeString(ks.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("Keystore: invalid salt")
+	}
+	nonce, err := hex.DecodeString(ks.Nonce)
+	if err != nil || len(nonce) != gost3412128.BlockSize || nonce[0]&0x80 != 0 {
+		return nil, fmt.Errorf("Keystore: invalid nonce")
+	}
+	ct, err := hex.DecodeString(ks.Ciphertext)
+	if err != nil || len(ct) < gost3412128.BlockSize {
+		return nil, fmt.Errorf("Keystore: invalid ciphertext")
+	}
+	aead, err := keystoreAEAD(pass, salt, ks.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := aead.Open(nil, nonce, ct, []byte(ks.Address))
+	if err != nil {
+		return nil, fmt.Errorf("Keystore: wrong passphrase or damaged keystore")
+	}
+	s, err := NewSignerFromKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Keystore: %s", err)
+	}
+	if name == "" {
+		name = ks.Name
+	}
+	w, err := walletWithSigner(name, s)
+	if err != nil {
+		return nil, err
+	}
+	if w.Addr != ks.Address {
+		return nil, fmt.Errorf("Keystore: key does not match address %s", ks.Address)
+	}
+	return w, nil
+}
@@ -0,0 +1,354 @@
+package ruscoin
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+// Verifies transaction against node utxo and mempool transactions and keeps it until it
+// is mined. Transaction may spend outputs of unconfirmed mempool transactions. Transaction
+// conflicting with mempool ones replaces them and their descendants if it pays more fee.
+// If node has block candidate transaction is added to it too
+func (n *Node) AddMempoolTransaction(t Transaction) error {
+	if n.MempoolHas(&t) {
+		return n.TransactionVerificatoinError("transaction is already in mempool")
+	}
+	if !n.mempoolUtxo().Contains(t.InputUtxo) {
+		return n.TransactionVerificatoinError("InputUtxo not found in node utxo and mempool outputs")
+	}
+	if t.Fee() < 0 {
+		return n.TransactionVerificatoinError("OutputUtxo sum exceeds InputUtxo sum")
+	}
+	if !CheckSign(t.SignBytes(), t.Sign, t.Pk) {
+		return n.TransactionVerificatoinError("wrong sign")
+	}
+	if err := t.checkMultisig(); err != nil {
+		return n.TransactionVerificatoinError(err.Error())
+	}
+	if REPLAY_PROTECTION {
+		if id := n.reusedUtxoId(&t, nil); id != "" {
+			return n.TransactionVerificatoinError(fmt.Sprintf("utxo id %s already used", id))
+		}
+	}
+	replaced := n.ReplacedBy(&t)
+	if err := checkReplacement(&t, replaced); err != nil {
+		return n.TransactionVerificatoinError(err.Error())
+	}
+	if n.BlockCandidate != nil && !n.candidateHas(&t) && !n.Policy.Censors(&t) {
+		if err := n.verifyTransaction(t, replaced); err != nil {
+			return err
+		}
+	}
+	n.removeMempool(replaced)
+	n.Mempool = append(n.Mempool, t.Clone())
+	if n.BlockCandidate != nil {
+		n.refillCandidate()
+	}
+	return nil
+}
+
+// Transaction with the same sign is in mempool
+func (n *Node) MempoolHas(t *Transaction) bool {
+	return txsHave(n.Mempool, t)
+}
+
+func (n *Node) candidateHas(t *Transaction) bool {
+	return txsHave(n.BlockCandidate.Body.Transactions, t)
+}
+
+func txsHave(txs []Transaction, t *Transaction) bool {
+	for i := range txs {
+		if bytes.Equal(txs[i].Sign, t.Sign) {
+			return true
+		}
+	}
+	return false
+}
+
+// Node utxo with outputs of mempool transactions, spendable by new mempool transaction
+func (n *Node) mempoolUtxo() UtxoList {
+	ul := n.Utxo.Clone()
+	for _, t := range n.Mempool {
+		ul.AddRecords(t.OutputUtxo)
+	}
+	return ul
+}
+
+// Child spends some output of parent
+func spendsOutput(child, parent *Transaction) bool {
+	for id := range child.InputUtxo {
+		if _, ok := parent.OutputUtxo[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Mempool transactions conflicting with t and all their descendants, which t replaces
+func (n *Node) ReplacedBy(t *Transaction) []Transaction {
+	res := []Transaction{}
+	for _, m := range n.Mempool {
+		if m.Conflicts(t) {
+			res = append(res, m)
+		}
+	}
+	for i := 0; i < len(res); i++ {
+		for _, m := range n.Mempool {
+			if spendsOutput(&m, &res[i]) && !txsHave(res, &m) {
+				res = append(res, m)
+			}
+		}
+	}
+	return res
+}
+
+// Replacement must pay more fee than all replaced transactions together and have higher
+// fee rate than every transaction it conflicts with
+func checkReplacement(t *Transaction, replaced []Transaction) error {
+	fee := 0
+	for _, r := range replaced {
+		if spendsOutput(t, &r) {
+			return fmt.Errorf("replacement spends output of replaced transaction")
+		}
+		if t.Conflicts(&r) && !higherFeeRate(t, &r) {
+			return fmt.Errorf("replacement fee rate is not higher than of conflicting transaction")
+		}
+		fee += r.Fee()
+	}
+	if len(replaced) > 0 && t.Fee() <= fee {
+		return fmt.Errorf("replacement fee %d must exceed fee %d of replaced transactions", t.Fee(), fee)
+	}
+	return nil
+}
+
+// Removes transactions from mempool and block candidate
+func (n *Node) removeMempool(txs []Transaction) {
+	if len(txs) == 0 {
+		return
+	}
+	n.Mempool = slices.DeleteFunc(n.Mempool, func(t Transaction) bool {
+		return txsHave(txs, &t)
+	})
+	if c := n.BlockCandidate; c != nil {
+		c.Body.Transactions = slices.DeleteFunc(c.Body.Transactions, func(t Transaction) bool {
+			return txsHave(txs, &t)
+		})
+		c.Header.Root = nil
+	}
+}
+
+// Removes mined and conflicting transactions: all inputs must be in node utxo or be
+// outputs of remaining mempool transactions. Repeats until children of removed parents
+// are gone too
+func (n *Node) pruneMempool() {
+	for {
+		ul := n.mempoolUtxo()
+		l := len(n.Mempool)
+		n.Mempool = slices.DeleteFunc(n.Mempool, func(t Transaction) bool {
+			return !ul.Contains(t.InputUtxo)
+		})
+		if len(n.Mempool) == l {
+			return
+		}
+	}
+}
+
+// Bytes of block candidate kept free for reward transaction
+const REWARD_TX_RESERVE = 256
+
+// Adds mempool transactions to block candidate, highest package fee rate first, while the
+// block fits into MAX_BLOCK_SIZE. Package is transaction with its unconfirmed ancestors, so
+// child paying high fee pulls its parents in. Transactions censored by miner policy are
+// skipped together with their descendants
+func (n *Node) fillCandidate() {
+	size := n.BlockCandidate.Size() + REWARD_TX_RESERVE
+	pf := newPackageFinder(n)
+	for {
+		pkg := pf.best()
+		if len(pkg) == 0 {
+			return
+		}
+		if size+pf.size(pkg) > MAX_BLOCK_SIZE {
+			pf.skip[pkg[len(pkg)-1]] = true
+			continue
+		}
+		for _, i := range pkg {
+			if err := n.AddVerifyTransaction(n.Mempool[i]); err != nil {
+				pf.skip[i] = true
+				break
+			}
+			pf.in[i] = true
+			size += pf.sizes[i]
+		}
+	}
+}
+
+// Takes mempool transactions out of block candidate and fills it again, so new transaction
+// with higher fee rate may push out cheaper ones. Transactions not from mempool stay
+func (n *Node) refillCandidate() {
+	c := n.BlockCandidate
+	c.Body.Transactions = slices.DeleteFunc(c.Body.Transactions, func(t Transaction) bool {
+		return n.MempoolHas(&t)
+	})
+	c.Header.Root = nil
+	n.fillCandidate()
+}
+
+// Packages of mempool transactions by indexes in node mempool
+type packageFinder struct {
+	n     *Node
+	sizes []int
+	// Mempool index of transaction creating utxo id
+	parents map[string]int
+	// Transactions in block candidate and the ones which can not get there
+	in   map[int]bool
+	skip map[int]bool
+}
+
+func newPackageFinder(n *Node) *packageFinder {
+	pf := &packageFinder{
+		n:       n,
+		sizes:   make([]int, len(n.Mempool)),
+		parents: make(map[string]int),
+		in:      make(map[int]bool),
+		skip:    make(map[int]bool),
+	}
+	for i := range n.Mempool {
+		t := &n.Mempool[i]
+		pf.sizes[i] = t.Size()
+		for id := range t.OutputUtxo {
+			pf.parents[id] = i
+		}
+		switch {
+		case n.candidateHas(t):
+			pf.in[i] = true
+		case n.Policy.Censors(t):
+			pf.skip[i] = true
+		}
+	}
+	return pf
+}
+
+// Transaction i with its ancestors which are not in block candidate, parents first.
+// False if some of them is skipped
+func (pf *packageFinder) ancestors(i int) ([]int, bool) {
+	pkg := []int{}
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		if pf.in[i] || slices.Contains(pkg, i) {
+			return true
+		}
+		if pf.skip[i] {
+			return false
+		}
+		for id := range pf.n.Mempool[i].InputUtxo.SortedItems() {
+			if p, ok := pf.parents[id]; ok && !visit(p) {
+				return false
+			}
+		}
+		pkg = append(pkg, i)
+		return true
+	}
+	return pkg, visit(i)
+}
+
+func (pf *packageFinder) size(pkg []int) int {
+	s := 0
+	for _, i := range pkg {
+		s += pf.sizes[i]
+	}
+	return s
+}
+
+func (pf *packageFinder) fee(pkg []int) int {
+	f := 0
+	for _, i := range pkg {
+		f += pf.n.Mempool[i].Fee()
+	}
+	return f
+}
+
+// Package with the highest fee rate. Equal rates keep mempool arrival order
+func (pf *packageFinder) best() []int {
+	var best []int
+	bestFee, bestSize := 0, 1
+	for i := range pf.n.Mempool {
+		if pf.in[i] || pf.skip[i] {
+			continue
+		}
+		pkg, ok := pf.ancestors(i)
+		if !ok {
+			continue
+		}
+		fee, size := pf.fee(pkg), pf.size(pkg)
+		if best == nil || fee*bestSize > bestFee*size {
+			best, bestFee, bestSize = pkg, fee, size
+		}
+	}
+	return best
+}
+
+// Fee per 1000 bytes
+func feeRate(fee, size int) int {
+	return fee * 1000 / max(size, 1)
+}
+
+// Fee rate at which mempool transaction gets mined: the highest rate of packages it is
+// part of, so child paying high fee raises the rate of its unconfirmed parent
+func (n *Node) EffectiveFeeRate(t *Transaction) int {
+	rate := t.FeeRate()
+	i := slices.IndexFunc(n.Mempool, func(m Transaction) bool {
+		return bytes.Equal(m.Sign, t.Sign)
+	})
+	if i < 0 {
+		return rate
+	}
+	pf := newPackageFinder(n)
+	clear(pf.in)
+	clear(pf.skip)
+	for j := range n.Mempool {
+		if pkg, _ := pf.ancestors(j); slices.Contains(pkg, i) {
+			rate = max(rate, feeRate(pf.fee(pkg), pf.size(pkg)))
+		}
+	}
+	return rate
+}
+
+// Mempool transactions censored by miner policy
+func (n *Node) CensoredMempool() []Transaction {
+	res := []Transaction{}
+	for _, t := range n.Mempool {
+		if n.Policy.Censors(&t) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Rules of the miner which transactions it refuses to mine
+type MinerPolicy struct {
+	// Addresses and utxo ids. Transaction is censored if it spends or pays to listed
+	// address or spends listed utxo. Empty list censors all transactions
+	Blacklist []string
+}
+
+func (p *MinerPolicy) Censors(t *Transaction) bool {
+	if p == nil {
+		return false
+	}
+	if len(p.Blacklist) == 0 {
+		return true
+	}
+	for id, u := range t.InputUtxo {
+		if slices.Contains(p.Blacklist, id) || slices.Contains(p.Blacklist, u.Addr) {
+			return true
+		}
+	}
+	for _, u := range t.OutputUtxo {
+		if slices.Contains(p.Blacklist, u.Addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package ruscoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+// Prefix of multisig addresses. Single key addresses are hex, so they never start with it
+const MULTISIG_PREFIX = "ms"
+
+// Max number of keys of multisig script
+const MULTISIG_MAX_KEYS = 15
+
+// m-of-n locking script. Output paid to its address is spent by transaction which reveals
+// the script and has signs of any M of its keys. Address is the hash of the script
+type Multisig struct {
+	M       int
+	PubKeys [][]byte
+}
+
+// Script of m of the keys. Keys are sorted, so the address does not depend on their order
+func NewMultisig(m int, pubKeys [][]byte) (*Multisig, error) {
+	keys := make([][]byte, len(pubKeys))
+	for i, k := range pubKeys {
+		keys[i] = bytes.Clone(k)
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	ms := &Multisig{M: m, PubKeys: keys}
+	if err := ms.validate(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (ms *Multisig) validate() error {
+	n := len(ms.PubKeys)
+	if n < 1 || n > MULTISIG_MAX_KEYS {
+		return fmt.Errorf("Multisig: number of keys %d is not in 1..%d", n, MULTISIG_MAX_KEYS)
+	}
+	if ms.M < 1 || ms.M > n {
+		return fmt.Errorf("Multisig: required signs %d is not in 1..%d", ms.M, n)
+	}
+	for i, k := range ms.PubKeys {
+		if len(k) != 2*int(gost3410.Mode2012) {
+			return fmt.Errorf("Multisig: key %d has invalid length", i)
+		}
+		if slices.ContainsFunc(ms.PubKeys[:i], func(o []byte) bool { return bytes.Equal(o, k) }) {
+			return fmt.Errorf("Multisig: key %d is repeated", i)
+		}
+	}
+	return nil
+}
+
+func (ms *Multisig) Bytes() []byte {
+	bf := new(bytes.Buffer)
+	bf.Write(IntToBytes(ms.M))
+	for _, k := range ms.PubKeys {
+		bf.Write(k)
+	}
+	return bf.Bytes()
+}
+
+func (ms *Multisig) Addr() string {
+	h, _ := GetHashGost3411(ms.Bytes())
+	return MULTISIG_PREFIX + hex.EncodeToString(h)
+}
+
+func (ms *Multisig) String() string {
+	return fmt.Sprintf("%d-of-%d", ms.M, len(ms.PubKeys))
+}
+
+func IsMultisigAddr(addr string) bool {
+	return strings.HasPrefix(addr, MULTISIG_PREFIX)
+}
+
+// Multisig script revealed by transaction spending its outputs with signs collected so far
+type MultisigSign struct {
+	Multisig
+	// Signs aligned with PubKeys, empty for keys which have not signed
+	Signs [][]byte
+}
+
+func (s *MultisigSign) Clone() MultisigSign {
+	c := MultisigSign{Multisig: Multisig{M: s.M}}
+	for _, k := range s.PubKeys {
+		c.PubKeys = append(c.PubKeys, bytes.Clone(k))
+	}
+	for _, sig := range s.Signs {
+		c.Signs = append(c.Signs, bytes.Clone(sig))
+	}
+	return c
+}
+
+// Number of valid signs of the message
+func (s *MultisigSign) Signed(msg []byte) int {
+	c := 0
+	for i, sig := range s.Signs {
+		if i < len(s.PubKeys) && len(sig) > 0 && CheckSign(msg, sig, s.PubKeys[i]) {
+			c++
+		}
+	}
+	return c
+}
+
+// Unsigned transaction spending multisig utxo. Payments to the same address are combined,
+// change goes back to the multisig address. Cosigners add signs by Wallet.SignMultisig
+func NewMultisigTransaction(ms *Multisig, inputs UtxoList, pays []Payment, fee int) (*Transaction, error) {
+	addr := ms.Addr()
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("Multisig %s: no utxo to spend", ms)
+	}
+	for id, u := range inputs {
+		if u.Addr != addr {
+			return nil, fmt.Errorf("Multisig %s: utxo %s is not paid to the multisig address", ms, id)
+		}
+	}
+	if fee < 0 {
+		return nil, fmt.Errorf("Multisig %s: fee is negative", ms)
+	}
+	outs := map[string]int{}
+	addrs := []string{}
+	total := fee
+	for _, p := range pays {
+		if p.Amount < 1 {
+			return nil, fmt.Errorf("Multisig %s: amount %d to %s is less then 1", ms, p.Amount, p.Addr)
+		}
+		if _, ok := outs[p.Addr]; !ok {
+			addrs = append(addrs, p.Addr)
+		}
+		outs[p.Addr] += p.Amount
+		total += p.Amount
+	}
+	if inputs.Sum() < total {
+		return nil, fmt.Errorf("Multisig %s: balance %d is not enough to pay %d", ms, inputs.Sum(), total)
+	}
+	output_utxo := NewUtxoList()
+	for _, a := range addrs {
+		output_utxo.NewRecord(a, outs[a])
+	}
+	if change := inputs.Sum() - total; change > 0 {
+		output_utxo.NewRecord(addr, change)
+	}
+	t := NewTransaction().SetInputUtxo(inputs.Clone()).SetOutputUtxo(output_utxo)
+	t.Multisig = []MultisigSign{{Multisig: *ms, Signs: make([][]byte, len(ms.PubKeys))}}
+	return t, nil
+}
+
+// Every multisig script of the transaction has enough signs
+func (t *Transaction) MultisigComplete() bool {
+	msg := t.SignBytes()
+	for i := range t.Multisig {
+		if t.Multisig[i].Signed(msg) < t.Multisig[i].M {
+			return false
+		}
+	}
+	return true
+}
+
+// Multisig rule of nodes: every input of multisig address is unlocked by its script with
+// at least M valid signs, every revealed script is spent by some input
+func (t *Transaction) checkMultisig() error {
+	msg := t.SignBytes()
+	scripts := map[string]bool{}
+	for i := range t.Multisig {
+		s := &t.Multisig[i]
+		if err := s.validate(); err != nil {
+			return err
+		}
+		a := s.Addr()
+		if _, ok := scripts[a]; ok {
+			return fmt.Errorf("Multisig: script %s is repeated", a)
+		}
+		if c := s.Signed(msg); c < s.M {
+			return fmt.Errorf("Multisig: script %s has %d valid signs of %d required", a, c, s.M)
+		}
+		scripts[a] = false
+	}
+	for id, u := range t.InputUtxo {
+		if !IsMultisigAddr(u.Addr) {
+			continue
+		}
+		if _, ok := scripts[u.Addr]; !ok {
+			return fmt.Errorf("Multisig: input %s has no script", id)
+		}
+		scripts[u.Addr] = true
+	}
+	for a, spent := range scripts {
+		if !spent {
+			return fmt.Errorf("Multisig: script %s spends no input", a)
+		}
+	}
+	return nil
+}
+
+// Adds wallet signs to multisig scripts of the transaction listing wallet key. The first
+// sign of the transaction is also its sign checked by nodes. Returns number of added signs
+func (w *Wallet) SignMultisig(t *Transaction) (int, error) {
+	if w.Offline {
+		return 0, w.Error("SignMultisig", "wallet is offline")
+	}
+	msg := t.SignBytes()
+	pk := w.S.PubKey.Raw()
+	added := 0
+	for i := range t.Multisig {
+		s := &t.Multisig[i]
+		k := slices.IndexFunc(s.PubKeys, func(o []byte) bool { return bytes.Equal(o, pk) })
+		if k < 0 || k >= len(s.Signs) || len(s.Signs[k]) > 0 {
+			continue
+		}
+		sig, err := w.S.Sign(msg)
+		if err != nil {
+			return added, w.Error("SignMultisig", "failed to sign transaction")
+		}
+		s.Signs[k] = sig
+		if len(t.Sign) == 0 {
+			t.SetSign(sig, pk)
+		}
+		added++
+	}
+	return added, nil
+}
@@ -16,6 +16,20 @@ type Node struct {
 	BlockChain     []*Block
 	BlockCandidate *Block
 	Neighbours     map[string]*Node
+	// Known transactions which are not mined yet
+	Mempool []Transaction
+	// Crashed node does not mine and does not receive blocks
+	Offline bool
+	// Relative mining power. Emulator selects miners proportionally to it
+	HashPower int
+	// Offset of the node clock from the common time
+	ClockSkew time.Duration
+	// Ids of spent utxo with height of the spending block
+	Spent map[string]int
+	// Transactions censored by the policy are not put into block candidate, nil - mine all
+	Policy *MinerPolicy
+	// Address to chain transactions spending or paying to it, in chain order
+	AddrIndex map[string][]TxRef
 }
 
 func NewNode(name string) (*Node, error) {
@@ -24,7 +38,10 @@ func NewNode(name string) (*Node, error) {
 		Id:             GenUniqueIdString(),
 		Utxo:           NewUtxoList(),
 		Neighbours:     make(map[string]*Node),
+		Spent:          make(map[string]int),
+		AddrIndex:      make(map[string][]TxRef),
 		BlockCandidate: nil,
+		HashPower:      1,
 	}
 	n.Utxo.Put(COINBASE_ADDR, COINBASE_ADDR, COINBASE_START_AMOUNT)
 	w, err := NewWallet(name)
@@ -73,6 +90,10 @@ func (n *Node) AddNeighbourList(nlist []*Node) {
 	}
 }
 
+func (n *Node) RemoveNeighbour(id string) {
+	delete(n.Neighbours, id)
+}
+
 func (n *Node) GetLastBlock() *Block {
 	l := len(n.BlockChain)
 	if l == 0 {
@@ -112,16 +133,124 @@ func (n *Node) AddVerifyBlock(b *Block) error {
 	return nil
 }
 
+// Verifies and adds blocks from src node chain which current node does not have yet.
+// Returns number of added blocks
+func (n *Node) SyncChain(src *Node) (int, error) {
+	added := 0
+	for h := len(n.BlockChain); h < len(src.BlockChain); h++ {
+		if err := n.AddVerifyBlock(src.BlockChain[h]); err != nil {
+			return added, n.Error("SyncChain", fmt.Sprintf("block %d from node %s rejected:\n%s", h, src.Name, err))
+		}
+		added++
+	}
+	return added, nil
+}
+
+// Switches node to the given chain if it is longer than the current one (longest chain rule).
+// Blocks after the fork point are verified, on failure current chain is kept.
+// Returns number of blocks removed from the current chain
+func (n *Node) ReceiveChain(chain []*Block) (int, error) {
+	if len(chain) <= len(n.BlockChain) {
+		return 0, n.Error("ReceiveChain", "chain is not longer than current")
+	}
+	defer n.rebaseCandidate()
+	f := n.ForkPoint(chain)
+	if f == len(n.BlockChain) {
+		for _, b := range chain[f:] {
+			if err := n.AddVerifyBlock(b); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	}
+	old := n.BlockChain
+	n.resetTo(old[:f])
+	for _, b := range chain[f:] {
+		if err := n.AddVerifyBlock(b); err != nil {
+			n.resetTo(old)
+			return 0, err
+		}
+	}
+	// transactions of orphaned blocks go back to mempool unless they conflict with the new chain
+	for _, b := range old[f:] {
+		for _, t := range b.Body.Transactions[1:] {
+			n.AddMempoolTransaction(t)
+		}
+	}
+	return len(old) - f, nil
+}
+
+// Moves block candidate with its transactions on top of the current chain
+func (n *Node) rebaseCandidate() {
+	if n.BlockCandidate == nil {
+		return
+	}
+	txs := n.BlockCandidate.Body.Transactions
+	n.NewBlockCandidate()
+	size := n.BlockCandidate.Size() + REWARD_TX_RESERVE
+	for _, t := range txs {
+		if !n.candidateHas(&t) && !n.Policy.Censors(&t) && n.Utxo.Contains(t.InputUtxo) && size+t.Size() <= MAX_BLOCK_SIZE {
+			n.BlockCandidate.AddTransaction(t)
+			size += t.Size()
+		}
+	}
+}
+
+// Height of the block which created each utxo of ul in node chain and whether it is output
+// of reward transaction. Utxo not found in the chain are left out
+func (n *Node) UtxoHeights(ul UtxoList) (map[string]int, map[string]bool) {
+	heights := make(map[string]int)
+	rewards := make(map[string]bool)
+	for h := len(n.BlockChain) - 1; h >= 0 && len(heights) < len(ul); h-- {
+		for i, t := range n.BlockChain[h].Body.Transactions {
+			for id := range t.OutputUtxo {
+				if _, ok := ul[id]; ok {
+					heights[id] = h
+					rewards[id] = i == 0
+				}
+			}
+		}
+	}
+	return heights, rewards
+}
+
+// Number of first blocks which are the same in node chain and the given chain
+func (n *Node) ForkPoint(chain []*Block) int {
+	f := 0
+	for f < len(chain) && f < len(n.BlockChain) && bytes.Equal(chain[f].Header.Hash, n.BlockChain[f].Header.Hash) {
+		f++
+	}
+	return f
+}
+
+// Replaces node chain with given verified blocks and rebuilds utxo from them
+func (n *Node) resetTo(chain []*Block) {
+	n.BlockChain = make([]*Block, 0, len(chain))
+	n.Utxo = NewUtxoList()
+	n.Utxo.Put(COINBASE_ADDR, COINBASE_ADDR, COINBASE_START_AMOUNT)
+	n.Spent = make(map[string]int)
+	n.AddrIndex = make(map[string][]TxRef)
+	for _, b := range chain {
+		n.addBlock(b)
+	}
+}
+
 func (n *Node) NewBlockCandidate() *Block {
 	n.BlockCandidate = nil
 	b := NewBlock()
 	b.Header.Height = len(n.BlockChain)
+	b.Header.Time = b.Header.Time.Add(n.ClockSkew)
 	lb := n.GetLastBlock()
 	if lb != nil {
 		b.Header.Prev = bytes.Clone(lb.Header.Hash)
 		b.Body.Coinbase = lb.Body.Coinbase
+		// block time must be after median time past, even if node clock is behind
+		if mtp := n.MedianTimePast(); !b.Header.Time.After(mtp) {
+			b.Header.Time = mtp.Add(time.Second)
+		}
 	}
 	n.BlockCandidate = b
+	n.fillCandidate()
 	return b
 }
 
@@ -133,11 +262,17 @@ func (n *Node) AddTransaction(t Transaction) {
 }
 
 func (n *Node) VerifyTransaction(t Transaction) error {
+	return n.verifyTransaction(t, nil)
+}
+
+// Verifies transaction against block candidate ignoring candidate transactions being
+// replaced. Transaction may spend outputs of candidate transactions, but not their inputs
+func (n *Node) verifyTransaction(t Transaction, replaced []Transaction) error {
 	var empty interface{}
 	inAmount := 0
 	outAmount := 0
 	utxoHash := make(map[string]interface{})
-	uids := n.candidateTransactionUtxoIds()
+	spent, created := n.candidateTransactionUtxoIds(replaced)
 
 	for id, u := range t.InputUtxo {
 		if _, ok := utxoHash[id]; ok {
@@ -145,8 +280,8 @@ func (n *Node) VerifyTransaction(t Transaction) error {
 		} else {
 			utxoHash[id] = empty
 		}
-		if _, ok := uids[id]; ok {
-			return n.TransactionVerificatoinError("Input Utxo already in block candidate transactions")
+		if _, ok := spent[id]; ok {
+			return n.TransactionVerificatoinError("Input Utxo already spent by block candidate transactions")
 		}
 		inAmount += u.Amount
 	}
@@ -157,14 +292,15 @@ func (n *Node) VerifyTransaction(t Transaction) error {
 		} else {
 			utxoHash[id] = empty
 		}
-		if _, ok := uids[id]; ok {
+		_, isSpent := spent[id]
+		if _, ok := created[id]; ok || isSpent {
 			return n.TransactionVerificatoinError("Output Utxo already in block candidate transactions")
 		}
 		outAmount += u.Amount
 	}
 
-	if inAmount != outAmount {
-		return n.TransactionVerificatoinError("InputUtxo and OutputUtxo sums are not equal")
+	if inAmount < outAmount {
+		return n.TransactionVerificatoinError("OutputUtxo sum exceeds InputUtxo sum")
 	}
 
 	return nil
@@ -204,6 +340,18 @@ func (n *Node) MineUnsafe() (*Block, error) {
 	return b, nil
 }
 
+// Mines given block with node's reward but does not add it to the chain.
+// Current block candidate of the node is kept
+func (n *Node) MineDetached(b *Block) (*Block, error) {
+	cur := n.BlockCandidate
+	n.BlockCandidate = b
+	defer func() { n.BlockCandidate = cur }()
+	if err := n.mineBlockCandidate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 func (n *Node) mineBlockCandidate() error {
 	if n.BlockCandidate == nil {
 		return n.Error("Mine", "No block candidate")
@@ -228,11 +376,15 @@ func (n *Node) AddRewardTransaction(b *Block) error {
 	if cb < REWARD_AMOUNT {
 		return n.Error("AddRewardTransaction", "Not enough coinbase")
 	}
+	fees := 0
+	if b.Header.Height > 0 {
+		fees = transactionFees(b.Body.Transactions)
+	}
 	rt := InitTransaction()
 	rt.Sign = []byte{}
 	rt.Pk = []byte{}
 	rt.InputUtxo.Put(COINBASE_ADDR, COINBASE_ADDR, cb)
-	rt.OutputUtxo.NewRecord(n.Wallet.Addr, REWARD_AMOUNT)
+	rt.OutputUtxo.NewRecord(n.Wallet.Addr, REWARD_AMOUNT+fees)
 	rt.OutputUtxo.Put(COINBASE_ADDR, COINBASE_ADDR, cb-REWARD_AMOUNT)
 	b.Body.Transactions = slices.Insert(b.Body.Transactions, 0, rt)
 	return nil
@@ -244,6 +396,11 @@ func (n *Node) VerifyBlock(b *Block) error {
 	// 	return n.VerificationError("Genesis block is not set")
 	// }
 
+	// Check block size
+	if size := b.Size(); size > MAX_BLOCK_SIZE {
+		return n.BlockVerificationError(fmt.Sprintf("Block size check failed: %d bytes, limit %d", size, MAX_BLOCK_SIZE))
+	}
+
 	// 1. Check merkle
 	root, err := b.CalcMerkleRoot()
 	if err != nil {
@@ -295,7 +452,7 @@ func (n *Node) verifyGenesisBlock(b *Block) error {
 		return n.BlockVerificationError("Genesis block invalid Prev hash")
 	}
 	// Check time
-	if time.Now().Before(b.Header.Time) {
+	if !n.checkFutureDrift(b) {
 		return n.BlockVerificationError("Genesis block: invalid time")
 	}
 	// Check coinbase
@@ -313,9 +470,12 @@ func (n *Node) verifyCommonBlock(b *Block) error {
 		return n.BlockVerificationError("Prev hash check failed")
 	}
 
-	// 5. Check time
-	if lastBlock.Header.Time.After(b.Header.Time) {
-		return n.BlockVerificationError("Time check failed")
+	// 5. Check time: after median time past and not too far in the future
+	if !b.Header.Time.After(n.MedianTimePast()) {
+		return n.BlockVerificationError("Time check failed: not after median time past")
+	}
+	if !n.checkFutureDrift(b) {
+		return n.BlockVerificationError("Time check failed: too far in the future")
 	}
 
 	// 6. coinbase check
@@ -323,20 +483,61 @@ func (n *Node) verifyCommonBlock(b *Block) error {
 		return n.BlockVerificationError("Coinbase check failed")
 	}
 
-	// 7,8,9
+	// 7,8,9. Utxo view is updated by every transaction, so transaction may spend outputs
+	// of earlier transaction of the same block, but not utxo spent already
+	view := n.Utxo.Clone()
 	for _, t := range b.Body.Transactions[1:] {
 		// 7 and 8. Input Utxo check
-		if !n.Utxo.Contains(t.InputUtxo) {
+		if !view.Contains(t.InputUtxo) {
 			return n.BlockVerificationError("InputUtxo check failed")
 		}
+		view.RemoveRecords(t.InputUtxo)
+		view.AddRecords(t.OutputUtxo)
 		// 9. Transaction sign check
-		if !CheckSign(t.Bytes(), t.Sign, t.Pk) {
+		if !CheckSign(t.SignBytes(), t.Sign, t.Pk) {
 			return n.BlockVerificationError("Transaction check failed")
 		}
+		// 9a. Multisig check: multisig inputs are unlocked by enough signs of their scripts
+		if err := t.checkMultisig(); err != nil {
+			return n.BlockVerificationError(fmt.Sprintf("Multisig check failed: %s", err))
+		}
+		// 10. Transaction balance check: fee is not negative
+		if t.Fee() < 0 {
+			return n.BlockVerificationError("Transaction balance check failed: outputs exceed inputs")
+		}
+	}
+
+	// 11. Replay check: new utxo ids were never used before
+	if REPLAY_PROTECTION {
+		seen := make(map[string]int)
+		for _, t := range b.Body.Transactions {
+			if id := n.reusedUtxoId(&t, seen); id != "" {
+				return n.BlockVerificationError(fmt.Sprintf("Replay check failed: utxo id %s already used", id))
+			}
+		}
 	}
 	return nil
 }
 
+// Output utxo id of transaction which is unspent, was spent before or is in seen ids.
+// Output ids are added to seen. Coinbase utxo is recreated by every block and is not checked
+func (n *Node) reusedUtxoId(t *Transaction, seen map[string]int) string {
+	for id := range t.OutputUtxo.SortedItems() {
+		if id == COINBASE_ADDR {
+			continue
+		}
+		_, spent := n.Spent[id]
+		_, dup := seen[id]
+		if spent || dup || n.Utxo.CheckId(id) {
+			return id
+		}
+		if seen != nil {
+			seen[id] = 0
+		}
+	}
+	return ""
+}
+
 func (n *Node) checkRewardTransaction(b *Block) error {
 	funcName := "CheckRewardTransaction"
 	if len(b.Body.Transactions) == 0 {
@@ -365,8 +566,8 @@ func (n *Node) checkRewardTransaction(b *Block) error {
 			if v.Amount != cbUtxo.Amount-REWARD_AMOUNT {
 				return n.Error(funcName, "OutputUtxo - coinbase - amount is wrong")
 			}
-		} else if v.Amount != REWARD_AMOUNT {
-			return n.Error(funcName, "OutputUtxo - miner - reward value is wrong")
+		} else if v.Amount != REWARD_AMOUNT+b.Fees() {
+			return n.Error(funcName, "OutputUtxo - miner - reward and fees value is wrong")
 		}
 	}
 	return nil
@@ -375,30 +576,42 @@ func (n *Node) checkRewardTransaction(b *Block) error {
 func (n *Node) addBlock(b *Block) {
 	bAdd := b.Clone()
 	n.BlockChain = append(n.BlockChain, bAdd)
-	for _, t := range b.Body.Transactions {
+	for i, t := range b.Body.Transactions {
 		n.Utxo.RemoveRecords(t.InputUtxo)
 		n.Utxo.AddRecords(t.OutputUtxo)
+		for id := range t.InputUtxo {
+			if id != COINBASE_ADDR {
+				n.Spent[id] = b.Header.Height
+			}
+		}
+		n.indexTransaction(&t, TxRef{Height: b.Header.Height, Index: i})
 	}
 	if n.Utxo[COINBASE_ADDR].Amount != b.Body.Coinbase {
 		n.Utxo[COINBASE_ADDR] = Utxo{Addr: COINBASE_ADDR, Amount: b.Body.Coinbase}
 	}
+	n.pruneMempool()
 }
 
-func (n *Node) candidateTransactionUtxoIds() map[string]interface{} {
+// Input and output utxo ids of block candidate transactions except the skipped ones
+func (n *Node) candidateTransactionUtxoIds(skip []Transaction) (map[string]interface{}, map[string]interface{}) {
 	var empty interface{}
-	r := make(map[string]interface{})
+	in := make(map[string]interface{})
+	out := make(map[string]interface{})
 	if n.BlockCandidate == nil {
-		return r
+		return in, out
 	}
 	for _, t := range n.BlockCandidate.Body.Transactions {
+		if txsHave(skip, &t) {
+			continue
+		}
 		for id := range t.InputUtxo {
-			r[id] = empty
+			in[id] = empty
 		}
 		for id := range t.OutputUtxo {
-			r[id] = empty
+			out[id] = empty
 		}
 	}
-	return r
+	return in, out
 }
 
 func MineBlock(b *Block) (int, []byte, error) {
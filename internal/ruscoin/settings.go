@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"os"
 	"strconv"
+	"time"
 )
 
 const (
@@ -18,8 +19,54 @@ var (
 	MINE_DIFF             string = "20"
 	MINE_BASE             string = "115792089237316195423570985008687907853269984665640564039457584007913129639936"
 	NONCE_MAX             int    = 2147483647
+	// Seed of deterministic mode, 0 - non deterministic
+	SEED int64 = 0
+	// Block time must be after median time of this number of last blocks
+	MTP_BLOCKS int = 11
+	// Block time may be ahead of the verifying node clock at most by this duration
+	MAX_FUTURE_DRIFT time.Duration = 2 * time.Hour
+	// Max serialised block size in bytes. Miners fill block candidate up to it, nodes reject bigger blocks
+	MAX_BLOCK_SIZE int = 100000
+	// Identifier of the emulated network, signed by transactions with replay protection
+	CHAIN_ID string = "ruscoin"
+	// Transaction signs commit to chain id and utxo ids, nodes reject reused utxo ids
+	REPLAY_PROTECTION bool = false
 )
 
+// Snapshot of package settings changed by emulations
+type Settings struct {
+	CoinbaseStart    int
+	Reward           int
+	MineDiff         string
+	Seed             int64
+	MaxBlockSize     int
+	ChainId          string
+	ReplayProtection bool
+}
+
+func CurrentSettings() Settings {
+	return Settings{
+		CoinbaseStart:    COINBASE_START_AMOUNT,
+		Reward:           REWARD_AMOUNT,
+		MineDiff:         MINE_DIFF,
+		Seed:             SEED,
+		MaxBlockSize:     MAX_BLOCK_SIZE,
+		ChainId:          CHAIN_ID,
+		ReplayProtection: REPLAY_PROTECTION,
+	}
+}
+
+// Sets package settings. Seed resets all random and time sources, see SetSeed
+func (s Settings) Apply() {
+	COINBASE_START_AMOUNT = s.CoinbaseStart
+	REWARD_AMOUNT = s.Reward
+	MINE_DIFF = s.MineDiff
+	MAX_BLOCK_SIZE = s.MaxBlockSize
+	CHAIN_ID = s.ChainId
+	REPLAY_PROTECTION = s.ReplayProtection
+	SetSeed(s.Seed)
+}
+
 func InitRuscoinSettings() error {
 	errStr := ""
 	if v := os.Getenv("COINBASE_START_AMOUNT"); v != "" {
@@ -54,6 +101,42 @@ func InitRuscoinSettings() error {
 		}
 	}
 
+	if v := os.Getenv("MAX_FUTURE_DRIFT"); v != "" {
+		if c, err := strconv.Atoi(v); err == nil {
+			MAX_FUTURE_DRIFT = time.Duration(c) * time.Second
+		} else {
+			errStr += "Failed to parse MAX_FUTURE_DRIFT env variable\n"
+		}
+	}
+
+	if v := os.Getenv("MAX_BLOCK_SIZE"); v != "" {
+		if c, err := strconv.Atoi(v); err == nil && c > 0 {
+			MAX_BLOCK_SIZE = c
+		} else {
+			errStr += "Failed to parse MAX_BLOCK_SIZE env variable\n"
+		}
+	}
+
+	if v := os.Getenv("CHAIN_ID"); v != "" {
+		CHAIN_ID = v
+	}
+
+	if v := os.Getenv("REPLAY_PROTECTION"); v != "" {
+		if c, err := strconv.ParseBool(v); err == nil {
+			REPLAY_PROTECTION = c
+		} else {
+			errStr += "Failed to parse REPLAY_PROTECTION env variable\n"
+		}
+	}
+
+	if v := os.Getenv("RUSCOIN_SEED"); v != "" {
+		if c, err := strconv.ParseInt(v, 10, 64); err == nil {
+			SetSeed(c)
+		} else {
+			errStr += "Failed to parse RUSCOIN_SEED env variable\n"
+		}
+	}
+
 	if errStr != "" {
 		return fmt.Errorf(errStr)
 	}
@@ -0,0 +1,94 @@
+package ruscoin
+
+import (
+	"crypto/rand"
+	"io"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// Start time of deterministic clock
+var DETERMINISTIC_START = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Source of current time for block creation and verification
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Deterministic clock. Every Now call moves time forward by step
+type StepClock struct {
+	mu   sync.Mutex
+	t    time.Time
+	step time.Duration
+}
+
+func NewStepClock(start time.Time, step time.Duration) *StepClock {
+	return &StepClock{t: start, step: step}
+}
+
+func (c *StepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(c.step)
+	return c.t
+}
+
+// Injectable sources. Replaced all together by SetSeed
+var (
+	TimeSource Clock = SystemClock{}
+	// Randomness for private keys
+	KeySource io.Reader = rand.Reader
+	// Randomness for signatures
+	SignSource io.Reader = rand.Reader
+	// Unique id generator for nodes and utxo records
+	IdSource func() string = func() string { return xid.New().String() }
+)
+
+// Sets seed for all sources. Zero seed restores system sources,
+// any other value makes keys, ids, signatures and block times reproducible
+func SetSeed(seed int64) {
+	SEED = seed
+	if seed == 0 {
+		TimeSource = SystemClock{}
+		KeySource = rand.Reader
+		SignSource = rand.Reader
+		IdSource = func() string { return xid.New().String() }
+		return
+	}
+	// Separate streams, so that e.g. extra signature does not change following keys and ids
+	master := mrand.New(mrand.NewSource(seed))
+	TimeSource = NewStepClock(DETERMINISTIC_START, time.Second)
+	KeySource = newLockedRand(master.Int63())
+	SignSource = newLockedRand(master.Int63())
+	ids := newLockedRand(master.Int63())
+	IdSource = func() string {
+		var id xid.ID
+		ids.Read(id[:])
+		return id.String()
+	}
+}
+
+// math/rand reader safe for concurrent use
+type lockedRand struct {
+	mu sync.Mutex
+	r  *mrand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: mrand.New(mrand.NewSource(seed))}
+}
+
+func (l *lockedRand) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
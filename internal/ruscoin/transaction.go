@@ -10,6 +10,8 @@ type Transaction struct {
 	OutputUtxo UtxoList
 	Sign       []byte
 	Pk         []byte
+	// Scripts and signs of spent multisig inputs
+	Multisig []MultisigSign
 }
 
 func InitTransaction() Transaction {
@@ -43,6 +45,36 @@ func (t *Transaction) FilterUtxoByWallet(addr string) (UtxoList, UtxoList) {
 	return inputUtxo, outputUtxo
 }
 
+// Coins left to the miner of the block: inputs minus outputs
+func (t *Transaction) Fee() int {
+	return t.InputUtxo.Sum() - t.OutputUtxo.Sum()
+}
+
+// Serialised transaction size in bytes
+func (t *Transaction) Size() int {
+	return len(t.Serialize())
+}
+
+// Transaction a pays more per byte than b
+func higherFeeRate(a, b *Transaction) bool {
+	return a.Fee()*b.Size() > b.Fee()*a.Size()
+}
+
+// Fee per 1000 bytes
+func (t *Transaction) FeeRate() int {
+	return feeRate(t.Fee(), t.Size())
+}
+
+// Transactions spend the same utxo
+func (t *Transaction) Conflicts(o *Transaction) bool {
+	for id := range t.InputUtxo {
+		if _, ok := o.InputUtxo[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Transaction) SignString() string {
 	return BytesToString(t.Sign)
 }
@@ -58,6 +90,24 @@ func (t *Transaction) Bytes() []byte {
 	return bf.Bytes()
 }
 
+// Message signed by the wallet. With replay protection it also commits to chain id and
+// utxo ids, so the sign is valid only in one network and only for the utxo it spends
+func (t *Transaction) SignBytes() []byte {
+	if !REPLAY_PROTECTION {
+		return t.Bytes()
+	}
+	bf := new(bytes.Buffer)
+	bf.WriteString(CHAIN_ID)
+	for id := range t.InputUtxo.SortedItems() {
+		bf.WriteString(id)
+	}
+	for id := range t.OutputUtxo.SortedItems() {
+		bf.WriteString(id)
+	}
+	bf.Write(t.Bytes())
+	return bf.Bytes()
+}
+
 func (t *Transaction) Clone() Transaction {
 	tt := Transaction{
 		InputUtxo:  t.InputUtxo.Clone(),
@@ -65,6 +115,9 @@ func (t *Transaction) Clone() Transaction {
 		Sign:       bytes.Clone(t.Sign),
 		Pk:         bytes.Clone(t.Pk),
 	}
+	for i := range t.Multisig {
+		tt.Multisig = append(tt.Multisig, t.Multisig[i].Clone())
+	}
 	return tt
 }
 
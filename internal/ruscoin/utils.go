@@ -3,8 +3,6 @@ package ruscoin
 import (
 	"encoding/binary"
 	"encoding/hex"
-
-	"github.com/rs/xid"
 )
 
 func reverseSlice[T any](d []T) {
@@ -21,7 +19,7 @@ func trimLeadingZeros(b []byte) []byte {
 }
 
 func GenUniqueIdString() string {
-	return xid.New().String()
+	return IdSource()
 }
 
 func SliceHasDuplicates[T comparable](a []T) bool {
@@ -1,6 +1,7 @@
 package ruscoin
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 )
@@ -10,6 +11,34 @@ type Wallet struct {
 	S    *Signer
 	Addr string
 	Utxo UtxoList
+	// Offline wallet can not create transactions
+	Offline bool
+	// Coin selection strategy of Send, largest first if empty
+	CoinSelection string
+	// Fee paid to the miner by every transaction of Send
+	Fee int
+	// Node the wallet syncs its utxo from, empty if not attached
+	NodeId string
+	// Hashes of node chain blocks scanned by Sync
+	scanned [][]byte
+	// Keys derived from seed phrase, nil for single key wallet
+	hd *hdWallet
+}
+
+// Address of HD wallet
+type WalletAddr struct {
+	Addr string
+	// HD_RECEIVE or HD_CHANGE
+	Branch int
+	// Position in the branch
+	Index int
+	Used  bool
+}
+
+// Recipient and amount of Wallet.SendMany
+type Payment struct {
+	Addr   string
+	Amount int
 }
 
 func NewWallet(name string) (*Wallet, error) {
@@ -17,6 +46,41 @@ func NewWallet(name string) (*Wallet, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create signer")
 	}
+	return walletWithSigner(name, s)
+}
+
+// HD wallet with new random seed phrase
+func NewHDWallet(name string) (*Wallet, error) {
+	m, err := NewMnemonic()
+	if err != nil {
+		return nil, err
+	}
+	return RestoreHDWallet(name, m)
+}
+
+// HD wallet of the seed phrase. The first receiving address is the wallet address,
+// utxo of all addresses are found by Rescan
+func RestoreHDWallet(name, mnemonic string) (*Wallet, error) {
+	m, err := NormalizeMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	hd, err := newHDWallet(m)
+	if err != nil {
+		return nil, err
+	}
+	addr := hd.addrs[HD_RECEIVE][0]
+	w := &Wallet{
+		Name: name,
+		S:    hd.keys[addr],
+		Addr: addr,
+		Utxo: NewUtxoList(),
+		hd:   hd,
+	}
+	return w, nil
+}
+
+func walletWithSigner(name string, s *Signer) (*Wallet, error) {
 	addr, err := GetHashGost3411(s.prvKey.Raw())
 	if err != nil {
 		return nil, fmt.Errorf("Failed to build client address")
@@ -38,6 +102,69 @@ func (w *Wallet) ErrorUtxmo(f, id, msg string) error {
 	return fmt.Errorf("Wallet %s [%s]: %s: utxo %s: %s", w.Name, w.Addr, f, id, msg)
 }
 
+// Wallet keys are derived from seed phrase
+func (w *Wallet) HD() bool {
+	return w.hd != nil
+}
+
+// Seed phrase of HD wallet, empty for single key wallet
+func (w *Wallet) Mnemonic() string {
+	if w.hd == nil {
+		return ""
+	}
+	return w.hd.mnemonic
+}
+
+// Address belongs to the wallet
+func (w *Wallet) Owns(addr string) bool {
+	if w.hd == nil {
+		return addr == w.Addr
+	}
+	_, ok := w.hd.keys[addr]
+	return ok
+}
+
+// Records of the list paying to wallet addresses
+func (w *Wallet) OwnUtxo(ul UtxoList) UtxoList {
+	res := NewUtxoList()
+	for id, u := range ul {
+		if w.Owns(u.Addr) {
+			res[id] = u
+		}
+	}
+	return res
+}
+
+// Address for the next incoming payment: the first unused receiving address of HD wallet
+func (w *Wallet) ReceiveAddr() string {
+	if w.hd == nil {
+		return w.Addr
+	}
+	return w.hd.unused(HD_RECEIVE)
+}
+
+// Address for change output: the first unused change address of HD wallet
+func (w *Wallet) ChangeAddr() string {
+	if w.hd == nil {
+		return w.Addr
+	}
+	return w.hd.unused(HD_CHANGE)
+}
+
+// Derived addresses of HD wallet, receiving first. Single key wallet has one address
+func (w *Wallet) Addrs() []WalletAddr {
+	if w.hd == nil {
+		return []WalletAddr{{Addr: w.Addr, Used: true}}
+	}
+	res := []WalletAddr{}
+	for b, addrs := range w.hd.addrs {
+		for i, a := range addrs {
+			res = append(res, WalletAddr{Addr: a, Branch: b, Index: i, Used: w.hd.used[a]})
+		}
+	}
+	return res
+}
+
 func (w *Wallet) Balance() int {
 	b := int(0)
 	for _, v := range w.Utxo {
@@ -47,7 +174,10 @@ func (w *Wallet) Balance() int {
 }
 
 func (w *Wallet) NewTransaction(inputIds []string, out_amount []int, addr string) (*Transaction, error) {
-	if addr == w.Addr {
+	if w.Offline {
+		return nil, w.Error("NewTransaction", "wallet is offline")
+	}
+	if w.Owns(addr) {
 		return nil, w.Error("NewTransaction", "Sending crypto to self not allowed")
 	}
 	if len(inputIds) != len(out_amount) {
@@ -76,7 +206,7 @@ func (w *Wallet) NewTransaction(inputIds []string, out_amount []int, addr string
 		input_utxo.Put(v, u.Addr, u.Amount)
 		output_utxo.NewRecord(addr, out_amount[i])
 		if d := u.Amount - out_amount[i]; d > 0 {
-			output_utxo.NewRecord(w.Addr, d)
+			output_utxo.NewRecord(w.ChangeAddr(), d)
 		}
 	}
 
@@ -91,15 +221,212 @@ func (w *Wallet) NewTransaction(inputIds []string, out_amount []int, addr string
 	return t, nil
 }
 
+// Creates transaction paying amount to address. Inputs are chosen by wallet coin selection
+func (w *Wallet) Send(amount int, to string) (*Transaction, error) {
+	return w.SendMany([]Payment{{Addr: to, Amount: amount}})
+}
+
+// Creates transaction with one output per recipient address and one change output.
+// Payments to the same address are combined, inputs cover payments and wallet fee
+func (w *Wallet) SendMany(pays []Payment) (*Transaction, error) {
+	return w.SendManyFrom(w.Utxo, pays)
+}
+
+// SendMany with inputs selected from coins instead of wallet utxo
+func (w *Wallet) SendManyFrom(coins UtxoList, pays []Payment) (*Transaction, error) {
+	if w.Offline {
+		return nil, w.Error("Send", "wallet is offline")
+	}
+	if len(pays) == 0 {
+		return nil, w.Error("Send", "no recipients")
+	}
+	outs := map[string]int{}
+	addrs := []string{}
+	total := 0
+	for _, p := range pays {
+		if w.Owns(p.Addr) {
+			return nil, w.Error("Send", "Sending crypto to self not allowed")
+		}
+		if p.Amount < 1 {
+			return nil, w.Error("Send", fmt.Sprintf("amount %d to %s is less then 1", p.Amount, p.Addr))
+		}
+		if _, ok := outs[p.Addr]; !ok {
+			addrs = append(addrs, p.Addr)
+		}
+		outs[p.Addr] += p.Amount
+		total += p.Amount
+	}
+	if w.Fee < 0 {
+		return nil, w.Error("Send", "fee is negative")
+	}
+	sel, err := NewCoinSelector(w.CoinSelection)
+	if err != nil {
+		return nil, w.Error("Send", err.Error())
+	}
+	ids, err := sel(coins, total+w.Fee)
+	if err != nil {
+		return nil, w.Error("Send", err.Error())
+	}
+
+	input_utxo := NewUtxoList()
+	for _, id := range ids {
+		u := coins[id]
+		input_utxo.Put(id, u.Addr, u.Amount)
+	}
+	output_utxo := NewUtxoList()
+	for _, a := range addrs {
+		output_utxo.NewRecord(a, outs[a])
+	}
+	if change := input_utxo.Sum() - total - w.Fee; change > 0 {
+		output_utxo.NewRecord(w.ChangeAddr(), change)
+	}
+
+	t := NewTransaction().SetInputUtxo(input_utxo).SetOutputUtxo(output_utxo)
+	if err := w.SignTransaction(t); err != nil {
+		return nil, w.Error("Send", "failed to sign transaction")
+	}
+	return t, nil
+}
+
+// Replacement of unconfirmed wallet transaction t with the same inputs paying fee instead
+// of t fee. Difference is taken from the change output
+func (w *Wallet) BumpFee(t *Transaction, fee int) (*Transaction, error) {
+	if w.Offline {
+		return nil, w.Error("BumpFee", "wallet is offline")
+	}
+	extra := fee - t.Fee()
+	if extra <= 0 {
+		return nil, w.Error("BumpFee", fmt.Sprintf("new fee %d must exceed current fee %d", fee, t.Fee()))
+	}
+	for _, u := range t.InputUtxo {
+		if !w.Owns(u.Addr) {
+			return nil, w.Error("BumpFee", "transaction spends utxo of other wallet")
+		}
+	}
+	change := w.OwnUtxo(t.OutputUtxo).Sum()
+	if change < extra {
+		return nil, w.Error("BumpFee", fmt.Sprintf("change %d is not enough to pay %d more", change, extra))
+	}
+
+	output_utxo := NewUtxoList()
+	for _, u := range t.OutputUtxo.SortedItems() {
+		if !w.Owns(u.Addr) {
+			output_utxo.NewRecord(u.Addr, u.Amount)
+		}
+	}
+	if change > extra {
+		output_utxo.NewRecord(w.ChangeAddr(), change-extra)
+	}
+	r := NewTransaction().SetInputUtxo(t.InputUtxo.Clone()).SetOutputUtxo(output_utxo)
+	if err := w.SignTransaction(r); err != nil {
+		return nil, w.Error("BumpFee", "failed to sign transaction")
+	}
+	return r, nil
+}
+
+// Child transaction spending wallet outputs of unconfirmed parent back to the wallet and
+// paying fee, so miners take the parent to get the child fee
+func (w *Wallet) ChildPays(parent *Transaction, fee int) (*Transaction, error) {
+	if w.Offline {
+		return nil, w.Error("ChildPays", "wallet is offline")
+	}
+	if fee < 1 {
+		return nil, w.Error("ChildPays", "fee is less then 1")
+	}
+	input_utxo := w.OwnUtxo(parent.OutputUtxo)
+	if len(input_utxo) == 0 {
+		return nil, w.Error("ChildPays", "parent has no outputs of the wallet")
+	}
+	if input_utxo.Sum() < fee {
+		return nil, w.Error("ChildPays", fmt.Sprintf("outputs %d are not enough to pay fee %d", input_utxo.Sum(), fee))
+	}
+	output_utxo := NewUtxoList()
+	if rest := input_utxo.Sum() - fee; rest > 0 {
+		output_utxo.NewRecord(w.ChangeAddr(), rest)
+	}
+	t := NewTransaction().SetInputUtxo(input_utxo).SetOutputUtxo(output_utxo)
+	if err := w.SignTransaction(t); err != nil {
+		return nil, w.Error("ChildPays", "failed to sign transaction")
+	}
+	return t, nil
+}
+
+// Scans blocks of node chain added since the last sync for wallet utxo. If node switched
+// to other fork, wallet rescans the whole chain. Returns number of scanned blocks which
+// are not in node chain any more
+func (w *Wallet) Sync(n *Node) int {
+	f := 0
+	for f < len(w.scanned) && f < len(n.BlockChain) && bytes.Equal(w.scanned[f], n.BlockChain[f].Header.Hash) {
+		f++
+	}
+	if undone := len(w.scanned) - f; undone > 0 {
+		w.Rescan(n)
+		return undone
+	}
+	for _, b := range n.BlockChain[f:] {
+		w.scanBlock(b)
+	}
+	return 0
+}
+
+// Forgets wallet utxo and scans node chain from genesis block. HD wallet derives new
+// addresses as it finds used ones, so rescan recovers all funds of the seed phrase
+func (w *Wallet) Rescan(n *Node) {
+	w.Utxo = NewUtxoList()
+	w.scanned = nil
+	if w.hd != nil {
+		w.hd.used = map[string]bool{}
+	}
+	for _, b := range n.BlockChain {
+		w.scanBlock(b)
+	}
+}
+
+// Height of the last scanned block, -1 if nothing is scanned
+func (w *Wallet) SyncHeight() int {
+	return len(w.scanned) - 1
+}
+
+func (w *Wallet) scanBlock(b *Block) {
+	for _, t := range b.Body.Transactions {
+		for id := range t.InputUtxo {
+			w.RemoveUtxo(id)
+		}
+		for id, u := range t.OutputUtxo {
+			if w.Owns(u.Addr) {
+				w.AddUtxo(id, u.Addr, u.Amount)
+				if w.hd != nil {
+					w.hd.markUsed(u.Addr)
+				}
+			}
+		}
+	}
+	w.scanned = append(w.scanned, b.Header.Hash)
+}
+
 func (w *Wallet) SignTransaction(t *Transaction) error {
-	sig, err := w.S.Sign(t.Bytes())
+	s := w.signer(t)
+	sig, err := s.Sign(t.SignBytes())
 	if err != nil {
 		return w.Error("SignTransaction", "Failed to sign transaction")
 	}
-	t.SetSign(sig, w.S.PubKey.Raw())
+	t.SetSign(sig, s.PubKey.Raw())
 	return nil
 }
 
+// Transaction carries one sign and nodes do not check which key owns the inputs, so HD
+// wallet signs with the key of its first input address
+func (w *Wallet) signer(t *Transaction) *Signer {
+	if w.hd != nil {
+		for _, u := range t.InputUtxo.SortedItems() {
+			if s, ok := w.hd.keys[u.Addr]; ok {
+				return s
+			}
+		}
+	}
+	return w.S
+}
+
 func (w *Wallet) RemoveUtxo(id string) {
 	delete(w.Utxo, id)
 }
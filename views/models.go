@@ -1,6 +1,9 @@
 package views
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const NoEnterEvent string = "if(event.keyCode === 13) {return false;}"
 
@@ -17,6 +20,21 @@ type NodeCellInput struct {
 	BNonce    string
 	BRoot     string
 	Miner     bool
+	Offline   bool
+	HashPower string
+	Selfish   bool
+	// Owner of attacker controlled node, empty for honest nodes
+	Owner string
+	// Share of Sybil neighbours in percents, empty if there are none
+	SybilShare string
+	// Behaviour kind and description with parameters
+	Behaviour     string
+	BehaviourDesc string
+	// Behaviour kinds to choose from
+	Behaviours []string
+	// Node clock offset in seconds and median time past of node chain
+	ClockSkew string
+	MTP       string
 }
 
 type NodeInfoSm struct {
@@ -37,28 +55,117 @@ type BlockInfoSmallItem struct {
 	Root     string
 	Time     string
 	TotalTr  string
+	// Serialised size in bytes and share of block size limit in percents
+	Size         string
+	Fullness     int
+	FullnessText string
 }
 
 type BlockTransactionItem struct {
 	Id, Sign, Pk string
 	InputUtxo    []UtxoItem
 	OutputUtxo   []UtxoItem
+	// Fee of user transaction, empty for reward transaction
+	Fee string
 }
 
 type UtxoItem struct {
 	Id, Addr, Amount string
 }
 
+// Wallet balances relative to the tip of the chosen node
+type WalletBalanceItem struct {
+	Node       string
+	Height     int
+	Confirmed  int
+	Available  int
+	Immature   int
+	PendingIn  int
+	PendingOut int
+}
+
+// Wallet utxo with number of confirmations, 0 for output of mempool transaction
+type WalletUtxoItem struct {
+	Id       string
+	Amount   int
+	Depth    int
+	Immature bool
+	Spending bool
+}
+
+// Unconfirmed wallet transaction in entry node mempool. Rates are fee per 1000 bytes,
+// effective rate includes children paying for the transaction
+type PendingTxItem struct {
+	Sign          string
+	Incoming      bool
+	Amount        int
+	Fee           int
+	FeeRate       int
+	EffectiveRate int
+}
+
+// Evil block as annotated hex text. Error is set if edited text failed to parse
+type EvilRawItem struct {
+	Text  string
+	Size  string
+	Error string
+}
+
 type EmulationSettingsItem struct {
 	CoinbaseAddr  string
 	CoinbaseStart string
 	RewardAmount  string
 	Diff          string
+	Seed          string
+	ChainId       string
+	// Replay protection on or off
+	ReplayProtection string
+	MaxBlockSize     string
+}
+
+type SchedulerItem struct {
+	Running     bool
+	TicksPerMin string
 }
 
 type SelectListItem struct {
-	Id   string
-	Name string
+	Id      string
+	Name    string
+	Offline bool
+	// Node the wallet syncs from
+	Node string
+}
+
+// Wallet transaction in the chain of the wallet node with balance after it
+type WalletHistoryItem struct {
+	Height       int
+	Time         string
+	Counterparty string
+	Received     int
+	Sent         int
+	Fee          int
+	Balance      int
+}
+
+// Address of HD wallet with coins on it
+type WalletAddrItem struct {
+	Addr   string
+	Change bool
+	Index  int
+	Used   bool
+	Amount int
+}
+
+// Multisig account with its pending spend
+type MultisigItem struct {
+	Name    string
+	Addr    string
+	Scheme  string
+	Owners  []string
+	Balance int
+	// Outputs of pending spend, empty if there is none
+	Pending []string
+	Signed  []string
 }
 
 type WalletBlockTrItem struct {
@@ -100,3 +207,139 @@ var (
 		},
 	}
 )
+
+type ScenarioItem struct {
+	Loaded      bool
+	Name        string
+	Description string
+	Pos         int
+	Failed      int
+	Steps       []ScenarioStepItem
+}
+
+type ScenarioStepItem struct {
+	N       string
+	Action  string
+	Comment string
+	Done    bool
+	OK      bool
+	Msg     string
+}
+
+type NetworkItem struct {
+	Mesh bool
+	// Partition and eclipse descriptions, empty if not active
+	Partition string
+	Eclipse   string
+	Cuts      []string
+	Nodes     []NetworkNodeItem
+}
+
+type NetworkNodeItem struct {
+	Name       string
+	Group      string
+	Height     string
+	Tip        string
+	Neighbours string
+	Offline    bool
+	Victim     bool
+	Attacker   bool
+}
+
+type DoubleSpendItem struct {
+	Exists        bool
+	Running       bool
+	Node          string
+	Victim        string
+	Amount        string
+	Confirmations string
+	// Height of public block with payment, empty while not mined
+	PayHeight     string
+	Current       string
+	PrivateLen    string
+	HashShare     string
+	Result        string
+	Reversed      bool
+	Safe          string
+	Probabilities []DoubleSpendProbItem
+}
+
+// Attacker success probability when victim waits Z confirmations
+type DoubleSpendProbItem struct {
+	Z string
+	P string
+	// Number of confirmations used in the attack
+	Used bool
+	Safe bool
+}
+
+type SybilItem struct {
+	Running  bool
+	Owner    string
+	Nodes    string
+	Mode     string
+	Targets  string
+	Censored string
+	Delayed  string
+	Withheld string
+	Held     string
+	Victims  []SybilVictimItem
+}
+
+// Honest node and share of its neighbours owned by Sybil attacker
+type SybilVictimItem struct {
+	Name  string
+	Share string
+	// All neighbours are Sybil nodes
+	Surrounded bool
+}
+
+type CensorshipItem struct {
+	// Censoring miners with their blacklists
+	Miners      string
+	Pending     string
+	AvgCensored string
+	AvgOther    string
+	Waits       []TxWaitItem
+}
+
+// Submitted transaction and ticks it waited for a block
+type TxWaitItem struct {
+	From      string
+	To        string
+	Amount    string
+	Submitted string
+	Waited    string
+	Skipped   string
+	Censored  bool
+	Pending   bool
+}
+
+type SelfishItem struct {
+	Running      bool
+	Node         string
+	Gamma        string
+	Withheld     string
+	Published    string
+	HashShare    string
+	RevenueShare string
+	// Revenue and hash share history in percents
+	Revenue []float64
+	Hash    []float64
+}
+
+// SVG polyline points for values in percents. Chart is w x h pixels
+func chartPoints(v []float64, w, h int) string {
+	if len(v) == 0 {
+		return ""
+	}
+	pts := make([]string, len(v))
+	for i, p := range v {
+		x := 0.0
+		if len(v) > 1 {
+			x = float64(i) * float64(w) / float64(len(v)-1)
+		}
+		pts[i] = fmt.Sprintf("%.1f,%.1f", x, float64(h)*(1-p/100))
+	}
+	return strings.Join(pts, " ")
+}